@@ -0,0 +1,157 @@
+package ast
+
+// arenaSlabSize is the number of nodes of each type allocated at once.
+const arenaSlabSize = 1024
+
+// arena hands out nodes from slabs so that parsing a large document
+// takes a few big allocations rather than one per node. Nodes from an
+// arena are ordinary values that may be modified or moved between
+// trees, but any node that is still referenced keeps its whole slab
+// alive. A nil *arena allocates each node individually.
+type arena struct {
+	literals []Literal
+	keys     []Key
+	fields   []Field
+	elements []Element
+	objects  []Object
+	arrays   []Array
+	comments []Comment
+
+	fieldPtrs   []*Field
+	elementPtrs []*Element
+
+	// names interns decoded keys, which repeat in arrays of similar
+	// objects.
+	names map[string]string
+}
+
+// Build the AST in slabs instead of allocating each node separately.
+// This cuts the number of allocations, and so the work for the GC, for
+// very large documents, particularly arrays of small objects. The tree
+// takes about as much memory either way.
+func OptionArena(p *astParser) {
+	p.arena = &arena{names: make(map[string]string)}
+}
+
+func (a *arena) newLiteral() *Literal {
+	if a == nil {
+		return &Literal{}
+	}
+	if len(a.literals) == cap(a.literals) {
+		a.literals = make([]Literal, 0, arenaSlabSize)
+	}
+	a.literals = a.literals[:len(a.literals)+1]
+	return &a.literals[len(a.literals)-1]
+}
+
+func (a *arena) newKey() *Key {
+	if a == nil {
+		return &Key{}
+	}
+	if len(a.keys) == cap(a.keys) {
+		a.keys = make([]Key, 0, arenaSlabSize)
+	}
+	a.keys = a.keys[:len(a.keys)+1]
+	return &a.keys[len(a.keys)-1]
+}
+
+func (a *arena) newField() *Field {
+	if a == nil {
+		return &Field{}
+	}
+	if len(a.fields) == cap(a.fields) {
+		a.fields = make([]Field, 0, arenaSlabSize)
+	}
+	a.fields = a.fields[:len(a.fields)+1]
+	return &a.fields[len(a.fields)-1]
+}
+
+func (a *arena) newElement() *Element {
+	if a == nil {
+		return &Element{}
+	}
+	if len(a.elements) == cap(a.elements) {
+		a.elements = make([]Element, 0, arenaSlabSize)
+	}
+	a.elements = a.elements[:len(a.elements)+1]
+	return &a.elements[len(a.elements)-1]
+}
+
+func (a *arena) newObject() *Object {
+	if a == nil {
+		return &Object{}
+	}
+	if len(a.objects) == cap(a.objects) {
+		a.objects = make([]Object, 0, arenaSlabSize)
+	}
+	a.objects = a.objects[:len(a.objects)+1]
+	return &a.objects[len(a.objects)-1]
+}
+
+func (a *arena) newArray() *Array {
+	if a == nil {
+		return &Array{}
+	}
+	if len(a.arrays) == cap(a.arrays) {
+		a.arrays = make([]Array, 0, arenaSlabSize)
+	}
+	a.arrays = a.arrays[:len(a.arrays)+1]
+	return &a.arrays[len(a.arrays)-1]
+}
+
+func (a *arena) newComment() *Comment {
+	if a == nil {
+		return &Comment{}
+	}
+	if len(a.comments) == cap(a.comments) {
+		a.comments = make([]Comment, 0, arenaSlabSize)
+	}
+	a.comments = a.comments[:len(a.comments)+1]
+	return &a.comments[len(a.comments)-1]
+}
+
+// fieldList returns a copy of fs sized exactly. The copy has no spare
+// capacity, so appending to it reallocates rather than overwriting its
+// neighbors in the slab.
+func (a *arena) fieldList(fs []*Field) []*Field {
+	if a == nil || len(fs) > arenaSlabSize/4 {
+		return append(make([]*Field, 0, len(fs)), fs...)
+	}
+	if len(fs) > cap(a.fieldPtrs)-len(a.fieldPtrs) {
+		a.fieldPtrs = make([]*Field, 0, arenaSlabSize)
+	}
+	start := len(a.fieldPtrs)
+	a.fieldPtrs = append(a.fieldPtrs, fs...)
+	return a.fieldPtrs[start:len(a.fieldPtrs):len(a.fieldPtrs)]
+}
+
+// elementList is like fieldList for array elements.
+func (a *arena) elementList(es []*Element) []*Element {
+	if a == nil || len(es) > arenaSlabSize/4 {
+		return append(make([]*Element, 0, len(es)), es...)
+	}
+	if len(es) > cap(a.elementPtrs)-len(a.elementPtrs) {
+		a.elementPtrs = make([]*Element, 0, arenaSlabSize)
+	}
+	start := len(a.elementPtrs)
+	a.elementPtrs = append(a.elementPtrs, es...)
+	return a.elementPtrs[start:len(a.elementPtrs):len(a.elementPtrs)]
+}
+
+// name returns the decoded key for raw, sharing the string with
+// earlier keys of the same name.
+func (a *arena) name(raw []byte) (string, bool) {
+	b, ok := unquoteKey(raw)
+	if !ok {
+		return "", false
+	}
+	if a == nil {
+		return string(b), true
+	}
+	if s, ok := a.names[string(b)]; ok {
+		return s, true
+	}
+	s := string(b)
+	a.names[s] = s
+	return s, true
+}
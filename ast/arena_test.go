@@ -0,0 +1,39 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArena(t *testing.T) {
+	in := `// Doc.
+[
+  {"id": 1, "name": "a"}, // One.
+  {"id": 2, "name": "b", "tags": [true, null]},
+  /* Three. */
+  {"id": 3, "name": "c", "nested": {"id": 4}},
+]
+`
+	want, err := ParseString(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseString(in, OptionArena)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatal("arena AST differs from the regular AST")
+	}
+	if out := string(FmtJsonr(got)); out != string(FmtJsonr(want)) {
+		t.Fatalf("arena AST formats differently:\n%s", out)
+	}
+
+	// Growing one container must not clobber its neighbors in the slab.
+	elts := got.Root.(*Array).Elements
+	first, second := elts[0].Value.(*Object), elts[1].Value.(*Object)
+	first.Set("extra", NewBool(true))
+	if f := second.Fields[0]; f.Key.Name != "id" {
+		t.Fatalf("sibling field overwritten: %s", f.Key.Name)
+	}
+}
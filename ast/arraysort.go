@@ -0,0 +1,251 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// ArraySort describes how to sort the elements of an array when
+// formatting. Elements keep their comments as they move.
+//
+// Elements are sorted by value: null, false and true come first, then
+// numbers by their numeric value, strings by their decoded bytes, and
+// arrays and objects by their canonical form. If By is set, objects are
+// sorted instead by the value of that field, and elements without it
+// come last in their original order.
+type ArraySort struct {
+	By     string // field of object elements to sort by
+	Unique bool   // remove elements without comments equal to an earlier one; see Equal
+}
+
+var (
+	nullLiteral     = []byte("null")
+	infinityLiteral = []byte("Infinity")
+)
+
+// SortDirective is the comment that marks an array to sort with
+// OptionSortDirectives. It may be followed by "by=field" and "unique",
+// as in:
+//
+//	// jsonr-fmt:sort by=name unique
+//	"deps": [...]
+const SortDirective = "jsonr-fmt:sort"
+
+// ParseArraySort parses the options of a sort directive, such as
+// "by=name unique".
+func ParseArraySort(options string) (ArraySort, error) {
+	var s ArraySort
+	for _, opt := range strings.Fields(options) {
+		switch {
+		case opt == "unique":
+			s.Unique = true
+		case strings.HasPrefix(opt, "by="):
+			s.By = opt[len("by="):]
+		default:
+			return s, fmt.Errorf("invalid array sort option %q", opt)
+		}
+	}
+	return s, nil
+}
+
+// Sort the elements of the arrays at path as s describes. The path is a
+// key path as written by FmtKeyAsPath, in which a * step matches any
+// key or index, such as "/services/*/allow". The AST is left as it is.
+func OptionSortArray(path string, s ArraySort) Option {
+	steps := []string{}
+	if path != "/" {
+		steps = splitKeyPath(strings.TrimPrefix(path, "/"))
+	}
+	return func(f *formatter) {
+		f.arraySorts = append(f.arraySorts, arraySortPath{steps, s})
+	}
+}
+
+// Sort the elements of arrays marked by a SortDirective in the comments
+// before the field or element holding them, or at the start of the file
+// for the root. Invalid directives are ignored. The AST is left as it
+// is.
+func OptionSortDirectives(f *formatter) {
+	f.sortDirectives = true
+}
+
+type arraySortPath struct {
+	steps []string
+	ArraySort
+}
+
+func (p *arraySortPath) match(keyPath []KeyStep) bool {
+	if len(p.steps) != len(keyPath) {
+		return false
+	}
+	for i, step := range p.steps {
+		if step != "*" && step != keyPath[i].String() {
+			return false
+		}
+	}
+	return true
+}
+
+// findSortDirectives returns the sorts given by directives for arrays
+// within node.
+func findSortDirectives(node Node) map[*Array]ArraySort {
+	sorts := make(map[*Array]ArraySort)
+	add := func(g *CommentGroup, v Value) {
+		a, ok := v.(*Array)
+		if !ok || g == nil {
+			return
+		}
+		for _, c := range g.List {
+			for _, line := range commentLines(c.Text) {
+				if line != SortDirective && !strings.HasPrefix(line, SortDirective+" ") {
+					continue
+				}
+				if s, err := ParseArraySort(line[len(SortDirective):]); err == nil {
+					sorts[a] = s
+				}
+			}
+		}
+	}
+	Inspect(node, func(n Node) bool {
+		switch n := n.(type) {
+		case *File:
+			add(n.Doc, n.Root)
+		case *Field:
+			add(n.Doc, n.Value)
+		case *Element:
+			add(n.Doc, n.Value)
+		}
+		return true
+	})
+	return sorts
+}
+
+// sortElements returns the elements of an array sorted as s describes,
+// leaving the array itself as it is. The elements are compared by
+// values, which hold the value of each element with any arrays within
+// it sorted.
+func sortElements(s ArraySort, elements []*Element, values []Value) []*Element {
+	keys := make([]sortKey, len(elements))
+	for i, v := range values {
+		keys[i] = elementSortKey(s.By, v)
+	}
+	order := make([]int, len(elements))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return keys[order[i]].less(&keys[order[j]])
+	})
+
+	sorted := make([]*Element, 0, len(elements))
+	for n, i := range order {
+		e := elements[i]
+		if s.Unique && n > 0 && e.Doc == nil && e.Comment == nil {
+			// Equal elements have equal sort keys, so they are adjacent
+			// once sorted.
+			dup := false
+			for k := n - 1; k >= 0 && keys[order[k]].equal(&keys[i]); k-- {
+				if Equal(values[order[k]], values[i]) {
+					dup = true
+					break
+				}
+			}
+			if dup {
+				continue
+			}
+		}
+		sorted = append(sorted, e)
+	}
+	return sorted
+}
+
+// sortKey is the part of an element that it is sorted by.
+type sortKey struct {
+	rank int // of the type of value; see ArraySort
+	num  *big.Float
+	text []byte // decoded string, or canonical array or object
+}
+
+const (
+	rankNull = iota
+	rankFalse
+	rankTrue
+	rankNumber
+	rankString
+	rankArray
+	rankObject
+	rankNone // no value, or no field to sort by
+)
+
+func elementSortKey(by string, v Value) sortKey {
+	if by != "" {
+		o, ok := v.(*Object)
+		if !ok {
+			return sortKey{rank: rankNone}
+		}
+		f := o.Get(by)
+		if f == nil {
+			return sortKey{rank: rankNone}
+		}
+		v = f.Value
+	}
+	switch v := v.(type) {
+	case *Literal:
+		switch v.Type {
+		case LiteralNull:
+			return sortKey{rank: rankNull}
+		case LiteralFalse:
+			return sortKey{rank: rankFalse}
+		case LiteralTrue:
+			return sortKey{rank: rankTrue}
+		case LiteralNumber:
+			num := jsonNumber(v.Value)
+			switch {
+			case !bytes.Equal(num, nullLiteral):
+			case bytes.Contains(v.Value, infinityLiteral):
+				return sortKey{rank: rankNumber, num: new(big.Float).SetInf(v.Value[0] == '-')}
+			default:
+				return sortKey{rank: rankNone} // NaN
+			}
+			n, _, err := big.ParseFloat(string(num), 10, 256, big.ToNearestEven)
+			if err != nil {
+				return sortKey{rank: rankNone}
+			}
+			return sortKey{rank: rankNumber, num: n}
+		case LiteralString:
+			s, _ := unquoteBytes(v.Value)
+			return sortKey{rank: rankString, text: s}
+		}
+	case *Array:
+		b, _ := FmtCanonical(v)
+		return sortKey{rank: rankArray, text: b}
+	case *Object:
+		b, _ := FmtCanonical(v)
+		return sortKey{rank: rankObject, text: b}
+	}
+	return sortKey{rank: rankNone}
+}
+
+func (k *sortKey) compare(l *sortKey) int {
+	if k.rank != l.rank {
+		if k.rank < l.rank {
+			return -1
+		}
+		return 1
+	}
+	if k.num != nil {
+		return k.num.Cmp(l.num)
+	}
+	return bytes.Compare(k.text, l.text)
+}
+
+func (k *sortKey) less(l *sortKey) bool {
+	return k.compare(l) < 0
+}
+
+func (k *sortKey) equal(l *sortKey) bool {
+	return k.compare(l) == 0
+}
@@ -0,0 +1,117 @@
+package ast
+
+import (
+	"testing"
+)
+
+func TestSortArray(t *testing.T) {
+	in := `{
+  "allow": [
+    "zeta",
+    // Needed for the beta.
+    "beta",
+    "alpha", // first
+    "zeta",
+  ],
+  "values": [{"a": 1}, "b", 2, null, [1], true, "a", 1e0, false, -Infinity],
+  "services": [
+    {"deps": [{"name": "b"}, {"name": "a", "v": 1}, {"v": 0}, {"name": "a", "v": 1}, 3]},
+    {"deps": [{"name": "d"}, {"name": "c"}]},
+  ],
+}`
+	f, err := Parse([]byte(in), OptionDialect(DialectInfinityNaN))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := string(FmtJsonr(f))
+
+	expected := `{
+  "allow": [
+    "alpha", // first
+    // Needed for the beta.
+    "beta",
+    "zeta",
+  ],
+  "values": [null, false, true, -Infinity, 1e0, 2, "a", "b", [1], {"a": 1}],
+  "services": [
+    {"deps": [{"name": "a", "v": 1}, {"name": "b"}, {"v": 0}, 3]},
+    {"deps": [{"name": "c"}, {"name": "d"}]},
+  ],
+}
+`
+	out := string(FmtJsonr(f,
+		OptionSortArray("/allow", ArraySort{Unique: true}),
+		OptionSortArray("/values", ArraySort{}),
+		OptionSortArray("/services/*/deps", ArraySort{By: "name", Unique: true}),
+	))
+	if out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	if out := string(FmtJsonr(f)); out != before {
+		t.Errorf("expected the tree to be left as it was, got:\n%s", out)
+	}
+}
+
+func TestSortDirectives(t *testing.T) {
+	in := `// jsonr-fmt:sort
+[
+  // jsonr-fmt:sort by=id unique
+  [{"id": 2}, {"id": 1}, {"id": 2}],
+  /* Not sorted:
+     jsonr-fmt:sort by=id bogus */
+  [{"id": 2}, {"id": 1}],
+  // Not a directive: jsonr-fmt:sort
+  [3, 2, 1],
+]
+`
+	f, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `// jsonr-fmt:sort
+[
+  // Not a directive: jsonr-fmt:sort
+  [3, 2, 1],
+  // jsonr-fmt:sort by=id unique
+  [{"id": 1}, {"id": 2}],
+  /* Not sorted:
+     jsonr-fmt:sort by=id bogus */
+  [{"id": 2}, {"id": 1}],
+]
+`
+	if out := string(FmtJsonr(f, OptionSortDirectives)); out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	if out := string(FmtJsonr(f)); out != in {
+		t.Errorf("expected directives to be ignored by default, got:\n%s", out)
+	}
+}
+
+func TestSortNestedArrays(t *testing.T) {
+	opts := []Option{
+		OptionSortArray("/", ArraySort{Unique: true}),
+		OptionSortArray("/*", ArraySort{}),
+	}
+	expected := "[[0, 2], [1, 3]]\n"
+	in := "[[1, 3], [2, 0], [0, 2]]"
+	for i := 0; i < 2; i++ {
+		f, err := ParseString(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := string(FmtJsonr(f, opts...))
+		if out != expected {
+			t.Fatalf("pass %d: expected %q, got %q", i+1, expected, out)
+		}
+		in = out
+	}
+}
+
+func TestParseArraySort(t *testing.T) {
+	if s, err := ParseArraySort(" by=name  unique "); err != nil || s != (ArraySort{By: "name", Unique: true}) {
+		t.Errorf("unexpected %+v, %v", s, err)
+	}
+	if _, err := ParseArraySort("by=name sideways"); err == nil {
+		t.Error("expected error")
+	}
+}
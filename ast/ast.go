@@ -4,41 +4,79 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"sort"
+	"strings"
 )
 
+// Node is implemented by all node types in the AST. The set of node
+// types is closed: *File, *Literal, *Object, *Array, *BadValue, *Field,
+// *Key, *Element, *Comment and *CommentGroup.
+type Node interface {
+	Pos() Pos // position of the first byte of the node
+	End() Pos // position immediately after the node
+	node()
+}
+
+// Value is implemented by the nodes that can appear as the root of a
+// File, the value of a Field or an Element: *Literal, *Object, *Array
+// and, when parsing with OptionRecover, *BadValue.
+type Value interface {
+	Node
+	valueNode()
+}
+
 type File struct {
 	Doc     *CommentGroup
-	Root    Node // we only have one root element.
+	Root    Value // we only have one root element.
 	Comment *CommentGroup
 }
 
 type Literal struct {
-	Type  LiteralType
-	Value []byte
+	ValuePos Pos
+	Type     LiteralType
+	Value    []byte
 }
 
 type Object struct {
 	Doc     *CommentGroup
+	Lbrace  Pos
 	Fields  []*Field
-	Comment *CommentGroup
+	Rbrace  Pos
+	Comment *CommentGroup // on lines of their own before the closing brace
 }
 
 type Field struct {
 	Doc     *CommentGroup
-	Name    Node
-	Value   Node
+	Key     *Key
+	Colon   Pos
+	Value   Value
 	Comment *CommentGroup
 }
 
+// Key is the name of an object field.
+type Key struct {
+	KeyPos Pos
+	Raw    []byte // quoted and escaped, as it appears in the source
+	Name   string // decoded
+}
+
 type Element struct {
 	Doc     *CommentGroup
-	Value   Node
+	Value   Value
 	Comment *CommentGroup
 }
 
 type Array struct {
+	Lbrack   Pos
 	Elements []*Element
+	Rbrack   Pos
+	Comment  *CommentGroup // on lines of their own before the closing bracket
+}
+
+// BadValue is a placeholder for malformed input in a tree parsed with
+// OptionRecover. Text is the source it replaces.
+type BadValue struct {
+	From, To Pos
+	Text     []byte
 }
 
 type LiteralType int
@@ -56,45 +94,155 @@ type CommentGroup struct {
 }
 
 type Comment struct {
-	Text []byte
+	Slash Pos
+	Text  []byte
+}
+
+// Pos and End return the position of the first byte of a node and the
+// position immediately after it. Comments are not included in the range
+// of the node they are attached to.
+
+func (f *File) Pos() Pos {
+	if f.Doc != nil {
+		return f.Doc.Pos()
+	}
+	if f.Root == nil {
+		return NoPos
+	}
+	return f.Root.Pos()
+}
+
+func (f *File) End() Pos {
+	if f.Comment != nil {
+		return f.Comment.End()
+	}
+	if f.Root == nil {
+		return NoPos
+	}
+	return f.Root.End()
+}
+
+func (l *Literal) Pos() Pos { return l.ValuePos }
+func (l *Literal) End() Pos {
+	if !l.ValuePos.IsValid() {
+		return NoPos
+	}
+	return l.ValuePos + Pos(len(l.Value))
 }
 
-type Node interface{}
+func (o *Object) Pos() Pos { return o.Lbrace }
+func (o *Object) End() Pos {
+	if !o.Rbrace.IsValid() {
+		return NoPos
+	}
+	return o.Rbrace + 1
+}
+
+func (a *Array) Pos() Pos { return a.Lbrack }
+func (a *Array) End() Pos {
+	if !a.Rbrack.IsValid() {
+		return NoPos
+	}
+	return a.Rbrack + 1
+}
+
+func (b *BadValue) Pos() Pos { return b.From }
+func (b *BadValue) End() Pos { return b.To }
+
+func (f *Field) Pos() Pos { return f.Key.Pos() }
+func (f *Field) End() Pos { return f.Value.End() }
+
+func (k *Key) Pos() Pos { return k.KeyPos }
+func (k *Key) End() Pos {
+	if !k.KeyPos.IsValid() {
+		return NoPos
+	}
+	return k.KeyPos + Pos(len(k.Raw))
+}
+
+func (e *Element) Pos() Pos { return e.Value.Pos() }
+func (e *Element) End() Pos { return e.Value.End() }
+
+func (c *Comment) Pos() Pos { return c.Slash }
+func (c *Comment) End() Pos {
+	if !c.Slash.IsValid() {
+		return NoPos
+	}
+	return c.Slash + Pos(len(c.Text))
+}
+
+func (g *CommentGroup) Pos() Pos { return g.List[0].Pos() }
+func (g *CommentGroup) End() Pos { return g.List[len(g.List)-1].End() }
+
+func (*File) node()         {}
+func (*Literal) node()      {}
+func (*Object) node()       {}
+func (*Array) node()        {}
+func (*BadValue) node()     {}
+func (*Field) node()        {}
+func (*Key) node()          {}
+func (*Element) node()      {}
+func (*Comment) node()      {}
+func (*CommentGroup) node() {}
+
+func (*Literal) valueNode()  {}
+func (*Object) valueNode()   {}
+func (*Array) valueNode()    {}
+func (*BadValue) valueNode() {}
 
 type Visitor interface {
 	Visit(node Node) (w Visitor)
 }
 
+// Walk traverses an AST in depth-first order, calling v.Visit for each
+// node including attached comment groups. If v.Visit returns nil the
+// children of the node are skipped.
 func Walk(v Visitor, node Node) {
-	//fmt.Printf("walk %#v\n", node)
 	if w := v.Visit(node); w == nil {
 		return
 	}
 
+	walkComments := func(g *CommentGroup) {
+		if g != nil {
+			Walk(v, g)
+		}
+	}
+
 	switch n := node.(type) {
 	case *File:
+		walkComments(n.Doc)
 		Walk(v, n.Root)
+		walkComments(n.Comment)
 	case *Literal:
 	case *Object:
+		walkComments(n.Doc)
 		for _, f := range n.Fields {
 			Walk(v, f)
 		}
+		walkComments(n.Comment)
 	case *Array:
 		for _, e := range n.Elements {
 			Walk(v, e)
 		}
+		walkComments(n.Comment)
+	case *BadValue:
 	case *Field:
-		Walk(v, n.Name)
+		walkComments(n.Doc)
+		Walk(v, n.Key)
 		Walk(v, n.Value)
+		walkComments(n.Comment)
+	case *Key:
 	case *Element:
+		walkComments(n.Doc)
 		Walk(v, n.Value)
+		walkComments(n.Comment)
 	case *Comment:
 	case *CommentGroup:
 		for _, c := range n.List {
 			Walk(v, c)
 		}
 	default:
-		fmt.Printf("Error %#v\n", n)
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
 	}
 }
 
@@ -112,55 +260,195 @@ func Inspect(node Node, f func(Node) bool) {
 }
 
 // Parse a string in JSONR syntax into an AST and return the root node.
-func Parse(in []byte) (Node, error) {
-	return (&astParser{}).Parse(in)
+// Node positions are as if the input were the first file added to a new
+// FileSet; use ParseFile to resolve them to lines and columns.
+func Parse(in []byte, options ...ParseOption) (*File, error) {
+	p := newParser(1, options)
+	in, err := sourceUTF8(in, p.strict)
+	if err != nil {
+		return nil, err
+	}
+	return p.Parse(in)
 }
 
-func ParseString(in string) (Node, error) {
-	return (&astParser{}).Parse([]byte(in))
+func ParseString(in string, options ...ParseOption) (*File, error) {
+	return Parse([]byte(in), options...)
+}
+
+// ParseAll parses a stream of JSONR values, such as JSONR Lines, into
+// one File per value. Comments on the same line as a value belong to
+// it; other comments are the doc of the value that follows them, and
+// any after the last value are its trailing comment.
+func ParseAll(in []byte, options ...ParseOption) ([]*File, error) {
+	p := newParser(1, options)
+	in, err := sourceUTF8(in, p.strict)
+	if err != nil {
+		return nil, err
+	}
+	return p.ParseAll(in)
+}
+
+// Parse the content of a file into an AST, adding the file to fset so
+// that node positions can be converted into file, line and column.
+func ParseFile(fset *FileSet, filename string, in []byte, options ...ParseOption) (*File, error) {
+	p := newParser(0, options)
+	in, err := sourceUTF8(in, p.strict)
+	if err != nil {
+		return nil, err
+	}
+	f := fset.AddFile(filename, in)
+	p.base = f.Base()
+	p.file = f
+	return p.Parse(in)
+}
+
+type ParseOption func(p *astParser)
+
+// Accept only what RFC 8259 allows, apart from comments and trailing
+// commas; see StripStrict.
+func OptionStrict(p *astParser) {
+	p.strict = true
 }
 
 type astParser struct {
-	lex       *lexer
-	item      *item
-	peekItems []*item
+	scan  scanner
+	tok   token
+	base  int // Pos of the first byte of input.
+	arena *arena
+
+	// Members of the objects and arrays being parsed, innermost last.
+	// Each container copies its own members out when it is closed.
+	fieldStack   []*Field
+	elementStack []*Element
+
+	// With OptionRecover, errors are collected here instead of ending
+	// the parse. file resolves their positions.
+	recover bool
+	errors  ErrorList
+	file    *SourceFile
+
+	strict  bool
+	dialect Dialect
 }
 
-func (p *astParser) next() *item {
-	if len(p.peekItems) > 0 {
-		p.item = p.peekItems[0]
-		p.peekItems = p.peekItems[1:]
-		return p.item
+func newParser(base int, options []ParseOption) *astParser {
+	p := &astParser{base: base}
+	for _, o := range options {
+		o(p)
 	}
-	p.item = p.lex.yield()
-	return p.item
+	return p
 }
 
-// Parse the input string into an AST.  This is only useful when you
-// are planning to programmatically manipulate the tree.
-func (p *astParser) Parse(input []byte) (Node, error) {
-	p.lex = lex("ast-parse-lexer", input)
+// pos returns the Pos of the current token.
+func (p *astParser) pos() Pos {
+	return Pos(p.base + p.tok.start)
+}
+
+// text returns the bytes of the current token.
+func (p *astParser) text() []byte {
+	return p.scan.text(&p.tok)
+}
+
+func (p *astParser) next() {
+	p.scan.scan(&p.tok)
+}
+
+func (p *astParser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Msg: fmt.Sprintf(format, args...), Offset: p.tok.start}
+}
+
+// fail returns err, or when recovering records it and returns nil so
+// that parsing continues. Only the first error at an offset is kept;
+// one problem tends to be reported again by each enclosing container.
+func (p *astParser) fail(err error) error {
+	if !p.recover {
+		return err
+	}
+	serr, ok := err.(*SyntaxError)
+	if !ok {
+		return err
+	}
+	pos := p.file.Position(Pos(p.base + serr.Offset))
+	if n := len(p.errors); n > 0 && p.errors[n-1].Pos.Offset == pos.Offset {
+		return nil
+	}
+	p.errors = append(p.errors, &Error{Pos: pos, Msg: serr.Msg})
+	return nil
+}
+
+// start resets the parser to read input.
+func (p *astParser) start(input []byte) {
+	p.scan = scanner{input: input, strict: p.strict, dialect: p.dialect}
+	p.errors = nil
+	if p.recover && p.file == nil {
+		p.file = newSourceFile("", p.base, input)
+	}
 	p.next()
+}
+
+// finish returns the errors collected while recovering, if any.
+func (p *astParser) finish() error {
+	if len(p.errors) == 0 {
+		return nil
+	}
+	p.errors.sort()
+	return p.errors
+}
+
+// Parse the input string into an AST.  This is only useful when you
+// are planning to programmatically manipulate the tree. The input has
+// already been through sourceUTF8.
+func (p *astParser) Parse(input []byte) (*File, error) {
+	p.start(input)
 	doc := p.parseCommentGroup()
-	elt, err := p.parseElement()
+	elt, err := p.parseElement(itemEOF)
 	if err != nil {
 		return nil, err
 	}
-	p.next()
 	comment := p.parseCommentGroup()
-	return &File{Doc: doc, Root: elt, Comment: comment}, nil
+	switch p.tok.typ {
+	case itemEOF:
+	case itemError:
+		err = p.scan.err
+	default:
+		err = p.errorf("unexpected %s after top-level value; use ParseAll for a stream of values", excerpt(p.text()))
+	}
+	if err := p.fail(err); err != nil {
+		return nil, err
+	}
+	return &File{Doc: doc, Root: elt, Comment: comment}, p.finish()
+}
+
+func (p *astParser) ParseAll(input []byte) ([]*File, error) {
+	p.start(input)
+	var files []*File
+	for {
+		doc := p.parseCommentGroup()
+		if p.tok.typ == itemEOF {
+			if doc != nil && len(files) > 0 {
+				last := files[len(files)-1]
+				last.Comment = joinComments(last.Comment, doc)
+			}
+			return files, p.finish()
+		}
+		elt, err := p.parseElement(itemEOF)
+		if err != nil {
+			return nil, err
+		}
+		comment := p.parseTrailingComment()
+		files = append(files, &File{Doc: doc, Root: elt, Comment: comment})
+	}
 }
 
 func (p *astParser) parseCommentGroup() *CommentGroup {
 	var cl []*Comment
 	for {
-		if p.item.typ == itemWhitespace {
+		if p.tok.typ == itemWhitespace {
 			p.next()
 			continue
 		}
-		if p.item.typ == itemComment {
-			cl = append(cl, &Comment{p.item.val})
-			p.next()
+		if p.tok.typ == itemComment {
+			cl = append(cl, p.parseComment())
 			continue
 		}
 		if len(cl) > 0 {
@@ -170,66 +458,187 @@ func (p *astParser) parseCommentGroup() *CommentGroup {
 	}
 }
 
-var (
-	_true  = &Literal{Type: LiteralTrue, Value: []byte("true")}
-	_false = &Literal{Type: LiteralFalse, Value: []byte("false")}
-	_null  = &Literal{Type: LiteralNull, Value: []byte("null")}
-)
+// parseTrailingComment collects the comments that follow a value on
+// the same line. Comments on the next line are left as the doc of
+// whatever follows.
+func (p *astParser) parseTrailingComment() *CommentGroup {
+	var cl []*Comment
+	for {
+		if p.tok.typ == itemWhitespace && bytes.IndexByte(p.text(), '\n') < 0 {
+			p.next()
+			continue
+		}
+		if p.tok.typ != itemComment {
+			break
+		}
+		cl = append(cl, p.parseComment())
+		// A line comment always ends the line.
+		if isLineComment(cl[len(cl)-1].Text) {
+			break
+		}
+	}
+	if len(cl) > 0 {
+		return &CommentGroup{cl}
+	}
+	return nil
+}
+
+func (p *astParser) parseComment() *Comment {
+	c := p.arena.newComment()
+	c.Slash, c.Text = p.pos(), p.text()
+	p.next()
+	return c
+}
 
-func (p *astParser) parseElement() (Node, error) {
-	switch p.item.typ {
+func joinComments(a, b *CommentGroup) *CommentGroup {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return &CommentGroup{append(a.List, b.List...)}
+}
+
+// parseElement parses a value and moves past it. close is the token
+// that ends the enclosing container, or itemEOF at the top level.
+func (p *astParser) parseElement(close itemType) (Value, error) {
+	var x Value
+	switch p.tok.typ {
 	case itemString:
-		for i, r := range p.item.val {
-			if r < '\u001f' {
-				return nil, fmt.Errorf("invalid literal character %q at position %d: control characters from \\u0000 - \\u001f must be escaped", r, p.item.start+i)
-			}
-		}
-		return &Literal{Type: LiteralString,
-			Value: p.item.val}, nil
+		x = p.parseLiteral(LiteralString)
 	case itemTrue:
-		return _true, nil
+		x = p.parseLiteral(LiteralTrue)
 	case itemFalse:
-		return _false, nil
+		x = p.parseLiteral(LiteralFalse)
 	case itemNull:
-		return _null, nil
+		x = p.parseLiteral(LiteralNull)
 	case itemNumber:
-		return &Literal{Type: LiteralNumber, Value: p.item.val}, nil
+		x = p.parseLiteral(LiteralNumber)
 	case itemArrayOpen:
 		return p.parseArray()
 	case itemObjectOpen:
 		return p.parseObject()
 	case itemError:
-		return nil, fmt.Errorf("%v", p.item)
+		if err := p.fail(p.scan.err); err != nil {
+			return nil, err
+		}
+		return p.badValue(close), nil
+	case itemEOF:
+		if err := p.fail(p.errorf("unexpected EOF, expected value")); err != nil {
+			return nil, err
+		}
+		return p.badValue(close), nil
 	default:
-		return nil, fmt.Errorf("unknown type: %v", p.item.typ)
+		if err := p.fail(p.errorf("unexpected %s, expected value", excerpt(p.text()))); err != nil {
+			return nil, err
+		}
+		return p.badValue(close), nil
 	}
+	p.next()
+	return x, nil
 }
 
-func (p *astParser) parseArray() (Node, error) {
-	x := &Array{Elements: make([]*Element, 0, 16)}
+// badValue skips malformed input up to the next comma or the close of
+// the enclosing container, or at the top level to the end of the line,
+// and returns it as a BadValue. Errors within it are not reported; the
+// caller has already reported the first.
+func (p *astParser) badValue(close itemType) *BadValue {
+	from, to := p.tok.start, p.tok.start
+	depth := 0
+skip:
+	for ; p.tok.typ != itemEOF; p.next() {
+		switch p.tok.typ {
+		case itemError:
+			p.scan.resync(&p.tok)
+		case itemWhitespace:
+			if close == itemEOF && depth == 0 && to > from && bytes.IndexByte(p.text(), '\n') >= 0 {
+				break skip
+			}
+			continue
+		case itemComment:
+			continue
+		case itemComma:
+			if close != itemEOF && depth == 0 {
+				break skip
+			}
+		case itemArrayOpen, itemObjectOpen:
+			depth++
+		case itemArrayClose, itemObjectClose:
+			if depth > 0 {
+				depth--
+			} else if p.tok.typ == close {
+				break skip
+			}
+			// Otherwise a stray closing bracket is part of the bad value.
+		}
+		to = p.tok.end
+	}
+	return &BadValue{From: Pos(p.base + from), To: Pos(p.base + to), Text: p.scan.input[from:to]}
+}
+
+func (p *astParser) parseLiteral(typ LiteralType) *Literal {
+	lit := p.arena.newLiteral()
+	lit.ValuePos, lit.Type, lit.Value = p.pos(), typ, p.text()
+	return lit
+}
+
+func (p *astParser) parseKey() (*Key, error) {
+	raw := p.text()
+	name, ok := p.arena.name(raw)
+	if !ok {
+		if err := p.fail(p.errorf("invalid key %s", excerpt(raw))); err != nil {
+			return nil, err
+		}
+	}
+	k := p.arena.newKey()
+	k.KeyPos, k.Raw, k.Name = p.pos(), raw, name
+	return k, nil
+}
+
+func (p *astParser) parseArray() (*Array, error) {
+	x := p.arena.newArray()
+	x.Lbrack = p.pos()
+	base := len(p.elementStack)
 	p.next()
+	// Whether a comma or the opening bracket precedes the next member.
+	sep := true
 	for {
 		doc := p.parseCommentGroup()
-		switch p.item.typ {
+		switch p.tok.typ {
 		case itemArrayClose:
+			x.Rbrack = p.pos()
+			p.next()
+			p.closeArray(x, base, doc)
 			return x, nil
 		case itemEOF:
-			return nil, fmt.Errorf("unexpected EOF reading array")
+			if err := p.fail(p.errorf("unexpected EOF reading array")); err != nil {
+				return nil, err
+			}
+			p.closeArray(x, base, doc)
+			return x, nil
 		default:
-			y, err := p.parseElement()
+			// A scanner error is more to the point than the missing comma.
+			if !sep && p.tok.typ != itemError {
+				if err := p.fail(p.errorf("missing comma in array")); err != nil {
+					return nil, err
+				}
+			}
+			y, err := p.parseElement(itemArrayClose)
 			if err != nil {
 				return nil, err
 			}
 
-			e := &Element{Doc: doc, Value: y}
-			x.Elements = append(x.Elements, e)
+			e := p.arena.newElement()
+			e.Doc, e.Value = doc, y
+			p.elementStack = append(p.elementStack, e)
 
-			p.next()
-			if p.item.typ == itemWhitespace {
+			if p.tok.typ == itemWhitespace {
 				p.next()
 			}
 
-			if p.item.typ == itemComma {
+			sep = p.tok.typ == itemComma
+			if sep {
 				p.next()
 			}
 
@@ -238,59 +647,101 @@ func (p *astParser) parseArray() (Node, error) {
 			// the current element. We don't support arbitrary whitespace
 			// while formatting. That's another kettle of fish at this
 			// point.
-			if p.item.typ == itemWhitespace && bytes.IndexByte(p.item.val, '\n') >= 0 {
+			if p.tok.typ == itemWhitespace && bytes.IndexByte(p.text(), '\n') >= 0 {
 				p.next()
 				continue
 			}
 
 			// Handle trailing comment regardless of trailing comma.
 			// FIXME(msolo) Having [ val /* comment */, ] seems visually confusing but legal.
-			e.Comment = p.parseCommentGroup()
+			e.Comment = p.parseTrailingComment()
 
 		}
 	}
 }
 
-func (p *astParser) parseObject() (Node, error) {
-	x := &Object{Fields: make([]*Field, 0, 16)}
+// closeArray moves the elements of x off the stack. Comments before
+// the closing bracket stay there, rather than join the last element.
+func (p *astParser) closeArray(x *Array, base int, doc *CommentGroup) {
+	elements := p.elementStack[base:]
+	x.Comment = doc
+	x.Elements = p.arena.elementList(elements)
+	p.elementStack = p.elementStack[:base]
+}
+
+func (p *astParser) parseObject() (*Object, error) {
+	x := p.arena.newObject()
+	x.Lbrace = p.pos()
+	base := len(p.fieldStack)
 	p.next() // skip {
+	// Whether a comma or the opening bracket precedes the next member.
+	sep := true
 	for {
 		doc := p.parseCommentGroup()
 		switch {
-		case p.item.typ == itemObjectClose:
+		case p.tok.typ == itemObjectClose:
+			x.Rbrace = p.pos()
+			p.next()
+			p.closeObject(x, base, doc)
+			return x, nil
+		case p.tok.typ == itemEOF:
+			if err := p.fail(p.errorf("unexpected EOF reading object")); err != nil {
+				return nil, err
+			}
+			p.closeObject(x, base, doc)
 			return x, nil
-		case p.item.typ == itemString:
-			key, err := p.parseElement()
+		case p.tok.typ == itemString || p.scan.isIdentKey(&p.tok):
+			if !sep {
+				if err := p.fail(p.errorf("missing comma in object")); err != nil {
+					return nil, err
+				}
+			}
+			key, err := p.parseKey()
 			if err != nil {
 				return nil, err
 			}
 
 			p.next()
-			if p.item.typ == itemWhitespace {
+			if p.tok.typ == itemWhitespace {
 				p.next()
 			}
-			if p.item.typ != itemColon {
-				return nil, fmt.Errorf("expected colon delimiter for key token")
-			}
-			p.next()
-			if p.item.typ == itemWhitespace {
+			colon := NoPos
+			if p.tok.typ == itemColon {
+				colon = p.pos()
 				p.next()
+				if p.tok.typ == itemWhitespace {
+					p.next()
+				}
+			} else if err := p.fail(p.errorf("expected colon delimiter for key token")); err != nil {
+				return nil, err
 			}
 
-			val, err := p.parseElement()
-			if err != nil {
-				return nil, err
+			var val Value
+			switch p.tok.typ {
+			case itemComma, itemObjectClose, itemEOF:
+				if colon == NoPos {
+					// The value is missing too; that is the same error.
+					val = p.badValue(itemObjectClose)
+					break
+				}
+				fallthrough
+			default:
+				val, err = p.parseElement(itemObjectClose)
+				if err != nil {
+					return nil, err
+				}
 			}
 
-			f := &Field{Doc: doc, Name: key, Value: val}
-			x.Fields = append(x.Fields, f)
+			f := p.arena.newField()
+			f.Doc, f.Key, f.Colon, f.Value = doc, key, colon, val
+			p.fieldStack = append(p.fieldStack, f)
 
-			p.next()
-			if p.item.typ == itemWhitespace {
+			if p.tok.typ == itemWhitespace {
 				p.next()
 			}
 
-			if p.item.typ == itemComma {
+			sep = p.tok.typ == itemComma
+			if sep {
 				p.next()
 			}
 
@@ -299,20 +750,43 @@ func (p *astParser) parseObject() (Node, error) {
 			// the current element. We don't support arbitrary whitespace
 			// while formatting. That's another kettle of fish at this
 			// point.
-			if p.item.typ == itemWhitespace && bytes.IndexByte(p.item.val, '\n') >= 0 {
+			if p.tok.typ == itemWhitespace && bytes.IndexByte(p.text(), '\n') >= 0 {
 				p.next()
 				continue
 			}
 			// Handle trailing comment regardless of trailing comma.
 			// FIXME(msolo) Having val /* comment */, } seems visually
 			// confusing but legal.
-			f.Comment = p.parseCommentGroup()
+			f.Comment = p.parseTrailingComment()
 		default:
-			return nil, fmt.Errorf("invalid key token %v", p.item)
+			err := error(p.scan.err)
+			if p.tok.typ != itemError {
+				err = p.errorf("invalid key token %s", excerpt(p.text()))
+			}
+			if err := p.fail(err); err != nil {
+				return nil, err
+			}
+			// Drop the malformed member.
+			if p.tok.typ != itemComma {
+				p.badValue(itemObjectClose)
+			}
+			sep = p.tok.typ == itemComma
+			if sep {
+				p.next()
+			}
 		}
 	}
 }
 
+// closeObject moves the fields of x off the stack. Comments before the
+// closing brace stay there, rather than join the last field.
+func (p *astParser) closeObject(x *Object, base int, doc *CommentGroup) {
+	fields := p.fieldStack[base:]
+	x.Comment = doc
+	x.Fields = p.arena.fieldList(fields)
+	p.fieldStack = p.fieldStack[:base]
+}
+
 func prettyFmt(data interface{}) string {
 	var p []byte
 	// Oh, the irony.
@@ -328,19 +802,227 @@ type formatter struct {
 	skipNextIndent     bool
 	skipComments       bool
 	elideTrailingComma bool
-	sortKeys           bool
+	keyOrder           KeyOrder
+	arraySorts         []arraySortPath
+	sortDirectives     bool
+	directives         map[*Array]ArraySort
+	conflicts          map[Node]*MergeConflict // by the node holding their place
+	conflictLabels     [2]string
+	keyPath            []KeyStep // of the node being formatted, if tracked
+	normalize          bool
+	json               bool
+	lineWidth          int
+	lineEnding         []byte
+	indentDelimiter    []byte
 	buf                *bytes.Buffer
 }
 
 var valueDelimiter = []byte(": ")
-var indentDelimiter = []byte("  ")
+var defaultIndent = []byte("  ")
+var inlineDelimiter = []byte(", ")
+
+// Containers that fit within this many columns are printed on a single line.
+const defaultLineWidth = 80
+
+// unlimitedLineWidth puts every container without comments on a single
+// line; see OptionUnlimitedLineWidth.
+const unlimitedLineWidth = -1
 
 func (f *formatter) indent() []byte {
 	if f.skipNextIndent {
 		f.skipNextIndent = false
 		return nil
 	}
-	return bytes.Repeat(indentDelimiter, f.indentLevel)
+	return bytes.Repeat(f.indentDelimiter, f.indentLevel)
+}
+
+// column returns the number of bytes written since the last newline.
+func (f *formatter) column() int {
+	buf := f.buf.Bytes()
+	return len(buf) - (bytes.LastIndexByte(buf, '\n') + 1)
+}
+
+// format formats a whole tree.
+func (f *formatter) format(node Node) []byte {
+	if f.sortDirectives {
+		f.directives = findSortDirectives(node)
+	}
+	f.fmtNode(node)
+	out := f.buf.Bytes()
+	// Comments and multi-line strings may contain either line ending.
+	if bytes.IndexByte(out, '\r') >= 0 {
+		out = bytes.Replace(out, crlf, lf, -1)
+	}
+	if f.lineEnding != nil && !bytes.Equal(f.lineEnding, lf) {
+		out = bytes.Replace(out, lf, f.lineEnding, -1)
+	}
+	return out
+}
+
+// tracking reports whether the key path of the node being formatted is
+// needed to order keys or elements.
+func (f *formatter) tracking() bool {
+	return f.keyOrder != nil || len(f.arraySorts) > 0
+}
+
+// enter and leave track the key path of the node being formatted.
+func (f *formatter) enter(step KeyStep) {
+	if f.tracking() {
+		f.keyPath = append(f.keyPath, step)
+	}
+}
+
+func (f *formatter) leave() {
+	if f.tracking() {
+		f.keyPath = f.keyPath[:len(f.keyPath)-1]
+	}
+}
+
+// fields returns the fields of an object in the order they are written.
+func (f *formatter) fields(o *Object) []*Field {
+	if f.keyOrder == nil {
+		return o.Fields
+	}
+	return sortFields(f.keyOrder, f.keyPath, o.Fields)
+}
+
+// elements returns the elements of an array in the order they are
+// written. A sort for the array's path takes precedence over a
+// directive.
+func (f *formatter) elements(a *Array) []*Element {
+	s, ok := f.arraySort(a)
+	if !ok {
+		return a.Elements
+	}
+	// Compare the elements as they will be written, so that formatting
+	// again leaves them in the same order.
+	values := make([]Value, len(a.Elements))
+	for i, e := range a.Elements {
+		f.enter(ByIdx(i))
+		values[i] = f.sortedValue(e.Value)
+		f.leave()
+	}
+	return sortElements(s, a.Elements, values)
+}
+
+// arraySort returns the sort that applies to an array, if any.
+func (f *formatter) arraySort(a *Array) (ArraySort, bool) {
+	for i := range f.arraySorts {
+		if s := &f.arraySorts[i]; s.match(f.keyPath) {
+			return s.ArraySort, true
+		}
+	}
+	s, ok := f.directives[a]
+	return s, ok
+}
+
+// sortedValue returns a copy of v with the arrays within it sorted as
+// they will be written, sharing the nodes that are not.
+func (f *formatter) sortedValue(v Value) Value {
+	switch v := v.(type) {
+	case *Array:
+		elements := f.elements(v)
+		sorted := *v
+		sorted.Elements = make([]*Element, len(elements))
+		for i, e := range elements {
+			f.enter(ByIdx(i))
+			sorted.Elements[i] = &Element{Doc: e.Doc, Value: f.sortedValue(e.Value), Comment: e.Comment}
+			f.leave()
+		}
+		return &sorted
+	case *Object:
+		sorted := *v
+		sorted.Fields = make([]*Field, len(v.Fields))
+		for i, fl := range v.Fields {
+			f.enter(ByName(fl.Key.Name))
+			c := *fl
+			c.Value = f.sortedValue(fl.Value)
+			sorted.Fields[i] = &c
+			f.leave()
+		}
+		return &sorted
+	}
+	return v
+}
+
+// fmtCompact writes a container on the current line if it has no
+// comments to preserve and fits within the line width, leaving room
+// for a trailing delimiter. It reports whether anything was written.
+func (f *formatter) fmtCompact(n Node) bool {
+	limit := -1
+	if f.lineWidth != unlimitedLineWidth {
+		if f.lineWidth <= 0 {
+			return false
+		}
+		limit = f.lineWidth - f.column() - 1
+		if limit <= 0 {
+			return false
+		}
+	}
+	var b bytes.Buffer
+	depth := len(f.keyPath)
+	if !f.fmtInline(&b, n, limit) {
+		f.keyPath = f.keyPath[:depth] // fmtInline gives up without leaving
+		return false
+	}
+	f.buf.Write(b.Bytes())
+	return true
+}
+
+// fmtInline renders a node on a single line. It gives up as soon as the
+// output exceeds limit bytes, unless limit is negative, or a comment
+// would be lost.
+func (f *formatter) fmtInline(b *bytes.Buffer, n Node, limit int) bool {
+	switch tn := n.(type) {
+	case *Literal:
+		if !f.json && bytes.IndexByte(tn.Value, '\n') >= 0 {
+			return false
+		}
+		b.Write(f.literal(tn))
+	case *Array:
+		if !f.skipComments && tn.Comment != nil {
+			return false
+		}
+		b.WriteByte('[')
+		for i, e := range f.elements(tn) {
+			if !f.skipComments && (e.Doc != nil || e.Comment != nil) || f.conflicts[e] != nil {
+				return false
+			}
+			if i > 0 {
+				b.Write(inlineDelimiter)
+			}
+			f.enter(ByIdx(i))
+			if !f.fmtInline(b, e.Value, limit) {
+				return false
+			}
+			f.leave()
+		}
+		b.WriteByte(']')
+	case *Object:
+		if !f.skipComments && tn.Comment != nil {
+			return false
+		}
+		b.WriteByte('{')
+		for i, fl := range f.fields(tn) {
+			if !f.skipComments && (fl.Doc != nil || fl.Comment != nil) || f.conflicts[fl] != nil {
+				return false
+			}
+			if i > 0 {
+				b.Write(inlineDelimiter)
+			}
+			b.Write(f.key(fl.Key))
+			b.Write(valueDelimiter)
+			f.enter(ByName(fl.Key.Name))
+			if !f.fmtInline(b, fl.Value, limit) {
+				return false
+			}
+			f.leave()
+		}
+		b.WriteByte('}')
+	default:
+		return false
+	}
+	return limit < 0 || b.Len() <= limit
 }
 
 func (f *formatter) fmtNode(n Node) []byte {
@@ -348,113 +1030,312 @@ func (f *formatter) fmtNode(n Node) []byte {
 		f.buf = bytes.NewBuffer(make([]byte, 0, 64))
 	}
 	b := f.buf
-
-	ensureNewline := func() {
-		if buf := b.Bytes(); len(buf) > 0 && buf[len(buf)-1] != '\n' {
-			b.WriteByte('\n')
-		}
-	}
+	ensureNewline := f.ensureNewline
 
 	switch tn := n.(type) {
 	case *File:
-		f.fmtNode(tn.Doc)
-		ensureNewline()
-		f.fmtNode(tn.Root)
-		ensureNewline()
-		f.fmtNode(tn.Comment)
-		ensureNewline()
+		f.fmtFileComments(tn.Doc)
+		if c := f.conflicts[tn.Root]; c != nil {
+			f.fmtConflict(c, func(n Node) {
+				f.fmtNode(n)
+				ensureNewline()
+			})
+		} else {
+			f.fmtNode(tn.Root)
+			ensureNewline()
+		}
+		f.fmtFileComments(tn.Comment)
 	case *Literal:
 		b.Write(f.indent())
-		b.Write(tn.Value)
+		b.Write(f.literal(tn))
+	case *BadValue:
+		b.Write(f.indent())
+		b.Write(tn.Text)
+	case *Key:
+		b.Write(f.indent())
+		b.Write(f.key(tn))
 	case *Array:
 		b.Write(f.indent())
+		if len(tn.Elements) != 0 && f.fmtCompact(tn) {
+			break
+		}
 		b.WriteByte('[')
-		if len(tn.Elements) != 0 {
+		if elements := f.elements(tn); len(elements) != 0 || f.hasFooter(tn.Comment) {
 			f.indentLevel++
 			b.WriteByte('\n')
-			for i, e := range tn.Elements {
-				f.fmtNode(e.Doc)
-				ensureNewline()
-				f.fmtNode(e.Value)
-				if f.elideTrailingComma {
-					if i != len(tn.Elements)-1 {
-						b.WriteByte(',')
-					}
+			for i, e := range elements {
+				last := i == len(elements)-1
+				if c := f.conflicts[e]; c != nil {
+					f.fmtConflict(c, func(n Node) {
+						f.fmtElement(i, n.(*Element), last)
+					})
 				} else {
-					b.WriteByte(',')
+					f.fmtElement(i, e, last)
 				}
-
-				if e.Comment != nil {
-					b.WriteByte(' ')
-					f.skipNextIndent = true
-					f.fmtNode(e.Comment)
-				}
-				ensureNewline()
 			}
+			f.fmtFooter(tn.Comment)
 			f.indentLevel--
 			b.Write(f.indent())
 		}
 		b.WriteByte(']')
 	case *Object:
 		b.Write(f.indent())
+		if len(tn.Fields) != 0 && f.fmtCompact(tn) {
+			break
+		}
 		b.WriteByte('{')
-		if len(tn.Fields) != 0 {
-			if f.sortKeys {
-				sort.Sort(byKey(tn.Fields))
-			}
-
+		if fields := f.fields(tn); len(fields) != 0 || f.hasFooter(tn.Comment) {
 			f.indentLevel++
 			b.WriteByte('\n')
-			for i, fl := range tn.Fields {
-				f.fmtNode(fl.Doc)
-				ensureNewline()
-				f.fmtNode(fl.Name)
-				b.Write(valueDelimiter)
-				f.skipNextIndent = true
-				f.fmtNode(fl.Value)
-				if f.elideTrailingComma {
-					if i != len(tn.Fields)-1 {
-						b.WriteByte(',')
-					}
+			for i, fl := range fields {
+				last := i == len(fields)-1
+				if c := f.conflicts[fl]; c != nil {
+					f.fmtConflict(c, func(n Node) {
+						f.fmtField(n.(*Field), last)
+					})
 				} else {
-					b.WriteByte(',')
-				}
-				if fl.Comment != nil {
-					b.WriteByte(' ')
-					f.skipNextIndent = true
-					f.fmtNode(fl.Comment)
+					f.fmtField(fl, last)
 				}
-				ensureNewline()
 			}
+			f.fmtFooter(tn.Comment)
 			f.indentLevel--
 			b.Write(f.indent())
 		}
 		b.WriteByte('}')
 	case *CommentGroup:
-		if f.skipComments {
-			// Whether or not we process this, reset indent.
-			f.skipNextIndent = false
-			return nil
+		f.fmtComments(tn)
+	}
+	return nil
+}
+
+func (f *formatter) ensureNewline() {
+	if buf := f.buf.Bytes(); len(buf) > 0 && buf[len(buf)-1] != '\n' {
+		f.buf.WriteByte('\n')
+	}
+}
+
+// fmtElement writes the element at index i of an array on its own
+// lines.
+func (f *formatter) fmtElement(i int, e *Element, last bool) {
+	f.fmtComments(e.Doc)
+	f.ensureNewline()
+	f.enter(ByIdx(i))
+	f.fmtNode(e.Value)
+	f.leave()
+	f.fmtMemberEnd(e.Comment, last)
+}
+
+// fmtField writes a field of an object on its own lines.
+func (f *formatter) fmtField(fl *Field, last bool) {
+	f.fmtComments(fl.Doc)
+	f.ensureNewline()
+	f.fmtNode(fl.Key)
+	f.buf.Write(valueDelimiter)
+	f.skipNextIndent = true
+	f.enter(ByName(fl.Key.Name))
+	f.fmtNode(fl.Value)
+	f.leave()
+	f.fmtMemberEnd(fl.Comment, last)
+}
+
+// fmtMemberEnd writes the comma and trailing comment after a field or
+// element.
+func (f *formatter) fmtMemberEnd(comment *CommentGroup, last bool) {
+	if !f.elideTrailingComma || !last {
+		f.buf.WriteByte(',')
+	}
+	if comment != nil && !f.skipComments {
+		f.buf.WriteByte(' ')
+		f.skipNextIndent = true
+		f.fmtComments(comment)
+	}
+	f.ensureNewline()
+}
+
+// hasFooter reports whether a container has comments before its
+// closing bracket to write.
+func (f *formatter) hasFooter(g *CommentGroup) bool {
+	return g != nil && !f.skipComments
+}
+
+// fmtFooter writes the comments before the closing bracket of a
+// container on lines of their own.
+func (f *formatter) fmtFooter(g *CommentGroup) {
+	if f.hasFooter(g) {
+		f.ensureNewline()
+		f.fmtComments(g)
+		f.ensureNewline()
+	}
+}
+
+// fmtFileComments writes the comments at the start or end of a file.
+func (f *formatter) fmtFileComments(g *CommentGroup) {
+	if c := f.conflicts[groupNode(g)]; c != nil {
+		f.fmtConflict(c, func(n Node) {
+			f.fmtComments(n.(*CommentGroup))
+			f.ensureNewline()
+		})
+		return
+	}
+	f.fmtComments(g)
+	f.ensureNewline()
+}
+
+// fmtConflict writes both sides of a conflict between conflict markers,
+// using side to write each one that is present.
+func (f *formatter) fmtConflict(c *MergeConflict, side func(n Node)) {
+	f.ensureNewline()
+	fmt.Fprintf(f.buf, "<<<<<<< %s\n", f.conflictLabels[0])
+	if c.Ours != nil {
+		side(c.Ours)
+	}
+	f.buf.WriteString("=======\n")
+	if c.Theirs != nil {
+		side(c.Theirs)
+	}
+	fmt.Fprintf(f.buf, ">>>>>>> %s\n", f.conflictLabels[1])
+}
+
+// literal returns the text of a literal, in JSON syntax if normalizing.
+// Multi-line strings are JSONR syntax, so they are only rewritten as
+// JSON by FmtJson; otherwise they are reindented to nest within the
+// current line.
+func (f *formatter) literal(l *Literal) []byte {
+	if l.Type == LiteralString && isTextBlock(l.Value) {
+		if f.json {
+			return jsonString(l.Value)
 		}
-		// FIXME(msolo) We return CommentGroup as a typed nil. This is a mess.
-		if tn == nil {
-			return nil
+		return reindentTextBlock(l.Value, bytes.Repeat(f.indentDelimiter, f.indentLevel+1))
+	}
+	if f.normalize {
+		switch l.Type {
+		case LiteralString:
+			return jsonString(l.Value)
+		case LiteralNumber:
+			return jsonNumber(l.Value)
 		}
-		for _, c := range tn.List {
-			b.Write(f.indent())
-			b.Write(c.Text)
-			if bytes.HasPrefix(c.Text, commentStart) {
-				b.WriteByte('\n')
-			}
+	}
+	return l.Value
+}
+
+// key returns the text of a key, in JSON syntax if normalizing.
+func (f *formatter) key(k *Key) []byte {
+	if f.normalize {
+		return jsonKey(k.Raw)
+	}
+	return k.Raw
+}
+
+// fmtComments writes a comment group, which may be nil.
+func (f *formatter) fmtComments(g *CommentGroup) {
+	if f.skipComments {
+		// Whether or not we process this, reset indent.
+		f.skipNextIndent = false
+		return
+	}
+	if g == nil {
+		return
+	}
+	for _, c := range g.List {
+		f.buf.Write(f.indent())
+		if f.normalize {
+			f.buf.Write(jsonComment(c.Text))
+		} else {
+			f.buf.Write(c.Text)
+		}
+		if isLineComment(c.Text) {
+			f.buf.WriteByte('\n')
 		}
 	}
-	return nil
 }
 
 type Option func(f *formatter)
 
+// Sort the keys of objects byte by byte. The AST is left as it is.
 func OptionSortKeys(f *formatter) {
-	f.sortKeys = true
+	f.keyOrder = KeyOrderLexical
+}
+
+// Order the keys of objects as order decides. The AST is left as it
+// is.
+func OptionKeyOrder(order KeyOrder) Option {
+	return func(f *formatter) {
+		f.keyOrder = order
+	}
+}
+
+// Rewrite syntax from a Dialect as JSONR: quote keys with double quotes,
+// write numbers as JSON and use // for line comments. FmtJson always
+// does this.
+func OptionNormalize(f *formatter) {
+	f.normalize = true
+}
+
+// Indent nested values with the given string, typically a tab or a
+// run of spaces. The default is two spaces.
+func OptionIndent(indent string) Option {
+	return func(f *formatter) {
+		f.indentDelimiter = []byte(indent)
+	}
+}
+
+// Indent nested values with width spaces.
+func OptionIndentWidth(width int) Option {
+	return OptionIndent(strings.Repeat(" ", width))
+}
+
+// Indent nested values the same way as the dominant indentation of
+// the JSONR source in. See DetectIndent.
+func OptionDetectIndent(in []byte) Option {
+	return OptionIndent(DetectIndent(in))
+}
+
+// End lines with nl, which is "\n" by default or "\r\n".
+func OptionLineEnding(nl string) Option {
+	return func(f *formatter) {
+		f.lineEnding = []byte(nl)
+	}
+}
+
+// End lines the same way as most lines of in. See DetectLineEnding.
+func OptionDetectLineEnding(in []byte) Option {
+	return OptionLineEnding(DetectLineEnding(in))
+}
+
+// Print arrays and objects without comments on a single line when they
+// fit within width columns. A width of 0 always expands them. The
+// default is 80.
+func OptionLineWidth(width int) Option {
+	if width < 0 {
+		width = 0
+	}
+	return func(f *formatter) {
+		f.lineWidth = width
+	}
+}
+
+// Print arrays and objects without comments on a single line however
+// long they are, so that FmtJson writes each value on one line as JSON
+// Lines requires.
+func OptionUnlimitedLineWidth(f *formatter) {
+	f.lineWidth = unlimitedLineWidth
+}
+
+// Write each of conflicts, as returned by Merge3 with the tree being
+// formatted, as both sides between Git-style conflict markers labelled
+// ours and theirs.
+func OptionConflictMarkers(conflicts []*MergeConflict, ours, theirs string) Option {
+	return func(f *formatter) {
+		f.conflicts = make(map[Node]*MergeConflict, len(conflicts))
+		for _, c := range conflicts {
+			if c.Ours != nil {
+				f.conflicts[c.Ours] = c
+			} else {
+				f.conflicts[c.Theirs] = c
+			}
+		}
+		f.conflictLabels = [2]string{ours, theirs}
+	}
 }
 
 // Format an AST according to JSON rules for backward compatibility.
@@ -462,30 +1343,25 @@ func FmtJson(node Node, options ...Option) []byte {
 	fmt := &formatter{
 		skipComments:       true,
 		elideTrailingComma: true,
+		normalize:          true,
+		json:               true,
+		lineWidth:          defaultLineWidth,
+		indentDelimiter:    defaultIndent,
 	}
 	for _, opt := range options {
 		opt(fmt)
 	}
-	fmt.fmtNode(node)
-	return fmt.buf.Bytes()
+	return fmt.format(node)
 }
 
 // Format an AST according to some aesthetic heuristics. Thanks gofmt.
 func FmtJsonr(node Node, options ...Option) []byte {
-	fmt := &formatter{}
+	fmt := &formatter{
+		lineWidth:       defaultLineWidth,
+		indentDelimiter: defaultIndent,
+	}
 	for _, o := range options {
 		o(fmt)
 	}
-	fmt.fmtNode(node)
-	return fmt.buf.Bytes()
-}
-
-type byKey []*Field
-
-func (a byKey) Len() int      { return len(a) }
-func (a byKey) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
-func (a byKey) Less(i, j int) bool {
-	return (bytes.Compare(
-		a[i].Name.(*Literal).Value,
-		a[j].Name.(*Literal).Value) < 0)
+	return fmt.format(node)
 }
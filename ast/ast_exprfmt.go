@@ -2,7 +2,6 @@ package ast
 
 import (
 	"bytes"
-	"fmt"
 	"strconv"
 	"strings"
 )
@@ -66,7 +65,16 @@ func (f *expFormatter) fmtNode(n Node) string {
 	case *Literal:
 		b.WriteString(f.fmtKeyPath(f.keyPath))
 		b.WriteString(" = ")
-		b.Write(tn.Value)
+		if tn.Type == LiteralString {
+			b.Write(jsonString(tn.Value))
+		} else {
+			b.Write(tn.Value)
+		}
+		ensureNewline()
+	case *BadValue:
+		b.WriteString(f.fmtKeyPath(f.keyPath))
+		b.WriteString(" = ")
+		b.Write(tn.Text)
 		ensureNewline()
 	case *Array:
 		if len(tn.Elements) != 0 {
@@ -82,13 +90,7 @@ func (f *expFormatter) fmtNode(n Node) string {
 		if len(tn.Fields) != 0 {
 			f.keyPath = append(f.keyPath, nil)
 			for _, fl := range tn.Fields {
-				v := fl.Name.(*Literal).Value
-				s, err := strconv.Unquote(string(v))
-				if err != nil {
-					panic(fmt.Errorf("unquote err: %s %s", err, v))
-				}
-
-				f.keyPath[len(f.keyPath)-1] = ByName(s)
+				f.keyPath[len(f.keyPath)-1] = ByName(fl.Key.Name)
 				b.WriteString(f.fmtNode(fl.Value))
 				ensureNewline()
 			}
@@ -1,7 +1,9 @@
 package ast
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/ianbruene/go-difflib/difflib"
@@ -13,6 +15,8 @@ func TestAstParse(t *testing.T) {
 		if err != nil {
 			t.Fatalf("parse failed: %#v, err: %T %s \n", input, err, err)
 		}
+		// Positions are checked separately.
+		clearPos(v)
 		if !reflect.DeepEqual(v, expectedVal) {
 			prettyExpected := prettyFmt(expectedVal)
 			prettyGot := prettyFmt(v)
@@ -114,18 +118,16 @@ func TestAstParse(t *testing.T) {
 `,
 		&File{
 			Root: &Array{
-				[]*Element{},
+				Elements: []*Element{},
 			},
 		},
 	)
 
-	checkParsedVal(`[
-  null,
-]
+	checkParsedVal(`[null]
 `,
 		&File{
 			Root: &Array{
-				[]*Element{
+				Elements: []*Element{
 					{
 						Value: &Literal{
 							Type:  LiteralNull,
@@ -146,17 +148,15 @@ func TestAstParse(t *testing.T) {
 		},
 	)
 
-	checkParsedVal(`{
-  "x": null,
-}
+	checkParsedVal(`{"x": null}
 `,
 		&File{
 			Root: &Object{
 				Fields: []*Field{
 					{
-						Name: &Literal{
-							Type:  LiteralString,
-							Value: []byte(`"x"`),
+						Key: &Key{
+							Raw:  []byte(`"x"`),
+							Name: "x",
 						},
 						Value: &Literal{
 							Type:  LiteralNull,
@@ -168,17 +168,15 @@ func TestAstParse(t *testing.T) {
 		},
 	)
 
-	checkParsedVal(`{
-  "quoted\"x": null,
-}
+	checkParsedVal(`{"quoted\"x": null}
 `,
 		&File{
 			Root: &Object{
 				Fields: []*Field{
 					{
-						Name: &Literal{
-							Type:  LiteralString,
-							Value: []byte(`"quoted\"x"`),
+						Key: &Key{
+							Raw:  []byte(`"quoted\"x"`),
+							Name: "quoted\"x",
 						},
 						Value: &Literal{
 							Type:  LiteralNull,
@@ -190,26 +188,22 @@ func TestAstParse(t *testing.T) {
 		},
 	)
 
-	checkParsedVal(`{
-  "x": {
-    "nested": null,
-  },
-}
+	checkParsedVal(`{"x": {"nested": null}}
 `,
 		&File{
 			Root: &Object{
 				Fields: []*Field{
 					{
-						Name: &Literal{
-							Type:  LiteralString,
-							Value: []byte(`"x"`),
+						Key: &Key{
+							Raw:  []byte(`"x"`),
+							Name: "x",
 						},
 						Value: &Object{
 							Fields: []*Field{
 								{
-									Name: &Literal{
-										Type:  LiteralString,
-										Value: []byte(`"nested"`),
+									Key: &Key{
+										Raw:  []byte(`"nested"`),
+										Name: "nested",
 									},
 									Value: &Literal{
 										Type:  LiteralNull,
@@ -252,9 +246,9 @@ func TestAstParse(t *testing.T) {
 			Root: &Object{
 				Fields: []*Field{
 					{
-						Name: &Literal{
-							Type:  LiteralString,
-							Value: []byte(`"x"`),
+						Key: &Key{
+							Raw:  []byte(`"x"`),
+							Name: "x",
 						},
 						Value: &Literal{
 							Type:  LiteralNull,
@@ -282,9 +276,9 @@ func TestAstParse(t *testing.T) {
 								},
 							},
 						},
-						Name: &Literal{
-							Type:  LiteralString,
-							Value: []byte(`"y"`),
+						Key: &Key{
+							Raw:  []byte(`"y"`),
+							Name: "y",
 						},
 						Value: &Literal{
 							Type:  LiteralNull,
@@ -308,6 +302,47 @@ func TestAstParse(t *testing.T) {
 	// checkParsedObject(` { "x" : null , } `, map[string]interface{}{"x": nil})
 }
 
+func TestAstParseErrors(t *testing.T) {
+	for _, in := range []string{
+		`[1 2]`, "[1\n2]", `{"a": 1 "b": 2}`, "{\"a\": 1\n\"b\": 2}", `{"a" 1}`,
+		`[1,`, `{"a": 1,`, `{1: 2}`, `nul`, `"unterminated`, "\"ctl\x01\"",
+	} {
+		if _, err := ParseString(in); err == nil {
+			t.Errorf("input %q: expected error", in)
+		}
+	}
+}
+
+func TestAstParseFooterComments(t *testing.T) {
+	// Comments before a closing bracket stay on lines of their own.
+	for in, expected := range map[string]string{
+		"{\"a\": 1,\n  // footer\n}":              "{\n  \"a\": 1,\n  // footer\n}\n",
+		"[1, 2 // two\n  /* end */\n]":            "[\n  1,\n  2, // two\n  /* end */\n]\n",
+		"{\"a\": [ // only\n]}":                   "{\n  \"a\": [\n    // only\n  ],\n}\n",
+		"{\"o\": {\"x\": 1,\n // f\n}, \"y\": 2}": "{\n  \"o\": {\n    \"x\": 1,\n    // f\n  },\n  \"y\": 2,\n}\n",
+	} {
+		f, err := ParseString(in)
+		if err != nil {
+			t.Errorf("input %q: %v", in, err)
+			continue
+		}
+		out := string(FmtJsonr(f))
+		if out != expected {
+			t.Errorf("input %q: expected %q, got %q", in, expected, out)
+		}
+		if f, err = ParseString(out); err != nil || string(FmtJsonr(f)) != out {
+			t.Errorf("input %q: formatting again changed it", in)
+		}
+	}
+	f, err := ParseString("{\"a\": [1,\n  // footer\n]}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(FmtJson(f)); out != "{\"a\": [1]}\n" {
+		t.Errorf("expected comments to be dropped, got %q", out)
+	}
+}
+
 func TestDumpPathEscaping(t *testing.T) {
 	s := `{
 		"a/b": [0,1]
@@ -342,3 +377,93 @@ func TestDumpExprEscaping(t *testing.T) {
 		t.Errorf("expected %s; got %s", expected, out)
 	}
 }
+
+func TestFmtLineWidth(t *testing.T) {
+	checkFmt := func(input, expected string, options ...Option) {
+		root, err := ParseString(input)
+		if err != nil {
+			t.Fatalf("parse failed: %#v, err: %s", input, err)
+		}
+		output := string(FmtJsonr(root, options...))
+		if output != expected {
+			t.Fatalf("expected formatted source:\n%s\ngot:\n%s", expected, output)
+		}
+		// Formatting must be idempotent.
+		root, err = ParseString(output)
+		if err != nil {
+			t.Fatalf("reparse failed: %#v, err: %s", output, err)
+		}
+		if again := string(FmtJsonr(root, options...)); again != output {
+			t.Fatalf("formatting not idempotent:\n%s\ngot:\n%s", output, again)
+		}
+	}
+
+	checkFmt(`[1,2,3]`, "[1, 2, 3]\n")
+	checkFmt(`{"x":1,"y":[2,{"z":3}]}`, "{\"x\": 1, \"y\": [2, {\"z\": 3}]}\n")
+	checkFmt(`[1,2,3]`, "[\n  1,\n  2,\n  3,\n]\n", OptionLineWidth(0))
+	checkFmt(`{"x": [1, 2], "y": 3}`, "{\n  \"x\": [1, 2],\n  \"y\": 3,\n}\n", OptionLineWidth(16))
+	x := strings.Repeat("x", 100)
+	long := `{"a": ["` + x + `", {"b": "` + x + `"}]}`
+	checkFmt(long, long+"\n", OptionUnlimitedLineWidth)
+	checkFmt(`[1, // one
+2]`, "[\n  1, // one\n  2,\n]\n", OptionUnlimitedLineWidth)
+	checkFmt(`[1, // one
+2]`, "[\n  1, // one\n  2,\n]\n")
+	checkFmt(`{"x": [1, 2], // trailer
+}`, "{\n  \"x\": [1, 2], // trailer\n}\n")
+	checkFmt(`["aaaaaaaaaa", "bbbbbbbbbb", "cccccccccc", "dddddddddd", "eeeeeeeeee", "ffffffffff"]`,
+		`[
+  "aaaaaaaaaa",
+  "bbbbbbbbbb",
+  "cccccccccc",
+  "dddddddddd",
+  "eeeeeeeeee",
+  "ffffffffff",
+]
+`)
+
+	root, err := ParseString(`[1, // one
+2]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(FmtJson(root)); out != "[1, 2]\n" {
+		t.Fatalf("expected compact JSON, got: %s", out)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	root, err := ParseString(`// doc
+{
+  // field doc
+  "a\/bé": [true, null], // trailer
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	var keys []string
+	Inspect(root, func(n Node) bool {
+		types = append(types, fmt.Sprintf("%T", n))
+		if k, ok := n.(*Key); ok {
+			keys = append(keys, k.Name)
+		}
+		return true
+	})
+	expected := []string{
+		"*ast.File", "*ast.CommentGroup", "*ast.Comment",
+		"*ast.Object",
+		"*ast.Field", "*ast.CommentGroup", "*ast.Comment", "*ast.Key",
+		"*ast.Array",
+		"*ast.Element", "*ast.Literal",
+		"*ast.Element", "*ast.Literal",
+		"*ast.CommentGroup", "*ast.Comment",
+	}
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("expected walk order %v, got %v", expected, types)
+	}
+	if !reflect.DeepEqual(keys, []string{"a/bé"}) {
+		t.Errorf("expected decoded key, got %q", keys)
+	}
+}
@@ -0,0 +1,130 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// FmtCanonical formats a value according to the JSON Canonicalization
+// Scheme of RFC 8785, so that values that differ only in comments,
+// whitespace, key order, string escapes or number spelling format to
+// the same bytes, suitable for hashing and signing. Comments and
+// whitespace are dropped, object keys are sorted by their UTF-16 code
+// units, strings are escaped minimally and numbers are written as
+// ECMAScript does, which may lose precision beyond that of a float64.
+//
+// If a key appears more than once the last field wins, as it does when
+// decoding. It is an error for a number to be out of the range of a
+// float64, for a string or key to hold invalid UTF-8 or an unpaired
+// surrogate, or for the tree to contain Infinity, NaN or a BadValue.
+func FmtCanonical(node Node) ([]byte, error) {
+	return appendCanonical(nil, node)
+}
+
+// validKey reports whether a key decodes to valid Unicode.
+func validKey(k *Key) bool {
+	if len(k.Raw) > 0 && (k.Raw[0] == '"' || k.Raw[0] == '\'') {
+		_, ok := unquoteExact(k.Raw)
+		return ok
+	}
+	return utf8.ValidString(k.Name)
+}
+
+func appendCanonical(b []byte, node Node) ([]byte, error) {
+	var err error
+	switch tn := node.(type) {
+	case *File:
+		return appendCanonical(b, tn.Root)
+	case *Literal:
+		switch tn.Type {
+		case LiteralString:
+			s, ok := unquoteExact(tn.Value)
+			if !ok {
+				return nil, fmt.Errorf("invalid string literal %q", tn.Value)
+			}
+			b = append(b, quote(string(s))...)
+		case LiteralNumber:
+			return appendCanonicalNumber(b, tn.Value)
+		default:
+			b = append(b, tn.Value...)
+		}
+	case *Array:
+		b = append(b, '[')
+		for i, e := range tn.Elements {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if b, err = appendCanonical(b, e.Value); err != nil {
+				return nil, err
+			}
+		}
+		b = append(b, ']')
+	case *Object:
+		fields := make([]canonicalField, len(tn.Fields))
+		for i, fl := range tn.Fields {
+			if !validKey(fl.Key) {
+				return nil, fmt.Errorf("invalid key %q", fl.Key.Raw)
+			}
+			fields[i] = canonicalField{utf16.Encode([]rune(fl.Key.Name)), fl}
+		}
+		sort.Stable(byUTF16(fields))
+		b = append(b, '{')
+		for i, cf := range fields {
+			if i+1 < len(fields) && fields[i+1].Key.Name == cf.Key.Name {
+				continue // a later duplicate wins
+			}
+			if b[len(b)-1] != '{' {
+				b = append(b, ',')
+			}
+			b = append(b, quote(cf.Key.Name)...)
+			b = append(b, ':')
+			if b, err = appendCanonical(b, cf.Value); err != nil {
+				return nil, err
+			}
+		}
+		b = append(b, '}')
+	case *BadValue:
+		return nil, fmt.Errorf("invalid value %q", tn.Text)
+	default:
+		return nil, fmt.Errorf("ast.FmtCanonical: unexpected node type %T", node)
+	}
+	return b, nil
+}
+
+// appendCanonicalNumber appends a number as ECMAScript's
+// Number.prototype.toString would.
+func appendCanonicalNumber(b, num []byte) ([]byte, error) {
+	f, err := strconv.ParseFloat(string(jsonNumber(num)), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("number %s cannot be canonicalized", num)
+	}
+	if f == 0 {
+		return append(b, '0'), nil // including -0
+	}
+	return append(b, formatFloat(f, 64)...), nil
+}
+
+type canonicalField struct {
+	key []uint16
+	*Field
+}
+
+// byUTF16 sorts fields by their names as UTF-16 code units, as RFC 8785
+// requires.
+type byUTF16 []canonicalField
+
+func (a byUTF16) Len() int      { return len(a) }
+func (a byUTF16) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byUTF16) Less(i, j int) bool {
+	x, y := a[i].key, a[j].key
+	for k := 0; k < len(x) && k < len(y); k++ {
+		if x[k] != y[k] {
+			return x[k] < y[k]
+		}
+	}
+	return len(x) < len(y)
+}
@@ -0,0 +1,141 @@
+package ast
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestFmtCanonical(t *testing.T) {
+	for _, tc := range []struct {
+		in, expected string
+	}{
+		// The example from RFC 8785 section 3.2.2.
+		{`{
+  "numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+  "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+  "literals": [null, true, false]
+}`, `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`},
+		// Sorting from RFC 8785 section 3.2.3.
+		{`{
+  "\u20ac": "Euro Sign",
+  "\r": "Carriage Return",
+  "\ufb33": "Hebrew Letter Dalet With Dagesh",
+  "1": "One",
+  "\ud83d\ude00": "Emoji: Grinning Face",
+  "\u0080": "Control",
+  "\u00f6": "Latin Small Letter O With Diaeresis"
+}`, "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"ö\":\"Latin Small Letter O With Diaeresis\",\"€\":\"Euro Sign\",\"😀\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}"},
+		{`/* c */ {"b": [1, {}, ], // c
+  "a": {"y": "", "x": -0.0},}`, `{"a":{"x":0,"y":""},"b":[1,{}]}`},
+		{`{"a": 1, "b": 2, "a": 3}`, `{"a":3,"b":2}`},
+		{"\"\"\"\n  x\n  \"\"\"", `"x\n"`},
+		{`[]`, `[]`},
+	} {
+		f, err := Parse([]byte(tc.in))
+		if err != nil {
+			t.Fatalf("input %s: %v", tc.in, err)
+		}
+		out, err := FmtCanonical(f)
+		if err != nil {
+			t.Errorf("input %s: %v", tc.in, err)
+		} else if string(out) != tc.expected {
+			t.Errorf("input %s: expected %s, got %s", tc.in, tc.expected, out)
+		}
+	}
+}
+
+func TestFmtCanonicalNumbers(t *testing.T) {
+	// From RFC 8785 appendix B.
+	for _, tc := range []struct {
+		bits     uint64
+		expected string
+	}{
+		{0x0000000000000000, "0"},
+		{0x8000000000000000, "0"},
+		{0x0000000000000001, "5e-324"},
+		{0x8000000000000001, "-5e-324"},
+		{0x7fefffffffffffff, "1.7976931348623157e+308"},
+		{0xffefffffffffffff, "-1.7976931348623157e+308"},
+		{0x4340000000000000, "9007199254740992"},
+		{0xc340000000000000, "-9007199254740992"},
+		{0x4430000000000000, "295147905179352830000"},
+		{0x44b52d02c7e14af5, "9.999999999999997e+22"},
+		{0x44b52d02c7e14af6, "1e+23"},
+		{0x44b52d02c7e14af7, "1.0000000000000001e+23"},
+		{0x444b1ae4d6e2ef4e, "999999999999999700000"},
+		{0x444b1ae4d6e2ef4f, "999999999999999900000"},
+		{0x444b1ae4d6e2ef50, "1e+21"},
+		{0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"},
+		{0x3eb0c6f7a0b5ed8d, "0.000001"},
+		{0x41b3de4355555553, "333333333.3333332"},
+		{0x41b3de4355555554, "333333333.33333325"},
+		{0x41b3de4355555555, "333333333.3333333"},
+		{0x41b3de4355555556, "333333333.3333334"},
+		{0x41b3de4355555557, "333333333.33333343"},
+		{0xbecbf647612f3696, "-0.0000033333333333333333"},
+		{0x43143ff3c1cb0959, "1424953923781206.2"},
+	} {
+		in := strconv.FormatFloat(math.Float64frombits(tc.bits), 'e', -1, 64)
+		out, err := FmtCanonical(&Literal{Type: LiteralNumber, Value: []byte(in)})
+		if err != nil {
+			t.Errorf("input %s: %v", in, err)
+		} else if string(out) != tc.expected {
+			t.Errorf("input %s: expected %s, got %s", in, tc.expected, out)
+		}
+	}
+
+	for _, in := range []string{"1e400", "-1e400"} {
+		if _, err := FmtCanonical(&Literal{Type: LiteralNumber, Value: []byte(in)}); err == nil {
+			t.Errorf("input %s: expected error", in)
+		}
+	}
+}
+
+func TestFmtCanonicalDialect(t *testing.T) {
+	f, err := Parse([]byte(`{b: 'it\'s', a: [0x10, .5, +1]}`), OptionDialect(DialectJSON5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"a":[16,0.5,1],"b":"it's"}`
+	if out, err := FmtCanonical(f); err != nil || string(out) != expected {
+		t.Errorf("expected %s, got %s, %v", expected, out, err)
+	}
+
+	f, err = Parse([]byte(`[1, Infinity]`), OptionDialect(DialectJSON5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FmtCanonical(f); err == nil {
+		t.Error("expected error for Infinity")
+	}
+
+	f, _ = Parse([]byte(`[1, ?]`), OptionRecover)
+	if _, err := FmtCanonical(f); err == nil {
+		t.Error("expected error for BadValue")
+	}
+}
+
+func TestFmtCanonicalInvalidUnicode(t *testing.T) {
+	// RFC 8785 requires strings to be valid Unicode, so that they do not
+	// all canonicalize to U+FFFD.
+	for _, in := range []string{`"\ud800"`, `"\udc00"`, `"\ud800A"`, "\"\xff\"", "'\xed\xa0\x80'"} {
+		if _, err := FmtCanonical(&Literal{Type: LiteralString, Value: []byte(in)}); err == nil {
+			t.Errorf("input %s: expected error", in)
+		}
+		o := &Object{Fields: []*Field{{Key: &Key{Raw: []byte(in)}, Value: &Literal{Type: LiteralNull, Value: []byte("null")}}}}
+		if _, err := FmtCanonical(o); err == nil {
+			t.Errorf("key %s: expected error", in)
+		}
+	}
+	for in, expected := range map[string]string{
+		`"\ufffd"`:       "\"\ufffd\"",
+		"\"\ufffd\"":     "\"\ufffd\"",
+		`"\ud83d\ude00"`: "\"\U0001f600\"",
+	} {
+		out, err := FmtCanonical(&Literal{Type: LiteralString, Value: []byte(in)})
+		if err != nil || string(out) != expected {
+			t.Errorf("input %s: expected %s, got %s, %v", in, expected, out, err)
+		}
+	}
+}
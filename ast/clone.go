@@ -0,0 +1,98 @@
+package ast
+
+import (
+	"fmt"
+)
+
+// Clone returns a deep copy of a node, including its comments, so that
+// the copy can be edited without affecting the original. The copy has
+// the same positions as the original and shares no memory with it, not
+// even with the source it was parsed from.
+func Clone(node Node) Node {
+	switch n := node.(type) {
+	case nil:
+		return nil
+	case *File:
+		return &File{Doc: cloneComments(n.Doc), Root: cloneValue(n.Root), Comment: cloneComments(n.Comment)}
+	case *Literal:
+		c := *n
+		c.Value = cloneBytes(n.Value)
+		return &c
+	case *Object:
+		c := *n
+		c.Doc = cloneComments(n.Doc)
+		c.Comment = cloneComments(n.Comment)
+		if n.Fields != nil {
+			c.Fields = make([]*Field, len(n.Fields))
+			for i, f := range n.Fields {
+				c.Fields[i] = Clone(f).(*Field)
+			}
+		}
+		return &c
+	case *Array:
+		c := *n
+		c.Comment = cloneComments(n.Comment)
+		if n.Elements != nil {
+			c.Elements = make([]*Element, len(n.Elements))
+			for i, e := range n.Elements {
+				c.Elements[i] = Clone(e).(*Element)
+			}
+		}
+		return &c
+	case *BadValue:
+		c := *n
+		c.Text = cloneBytes(n.Text)
+		return &c
+	case *Field:
+		c := *n
+		c.Doc = cloneComments(n.Doc)
+		c.Key = Clone(n.Key).(*Key)
+		c.Value = cloneValue(n.Value)
+		c.Comment = cloneComments(n.Comment)
+		return &c
+	case *Key:
+		c := *n
+		c.Raw = cloneBytes(n.Raw)
+		return &c
+	case *Element:
+		c := *n
+		c.Doc = cloneComments(n.Doc)
+		c.Value = cloneValue(n.Value)
+		c.Comment = cloneComments(n.Comment)
+		return &c
+	case *Comment:
+		c := *n
+		c.Text = cloneBytes(n.Text)
+		return &c
+	case *CommentGroup:
+		return cloneComments(n)
+	}
+	panic(fmt.Sprintf("ast.Clone: unexpected node type %T", node))
+}
+
+// cloneValue is Clone for a Value, which may be nil.
+func cloneValue(v Value) Value {
+	if v == nil {
+		return nil
+	}
+	return Clone(v).(Value)
+}
+
+// cloneComments is Clone for a CommentGroup, which may be nil.
+func cloneComments(g *CommentGroup) *CommentGroup {
+	if g == nil {
+		return nil
+	}
+	c := &CommentGroup{List: make([]*Comment, len(g.List))}
+	for i, cm := range g.List {
+		c.List[i] = Clone(cm).(*Comment)
+	}
+	return c
+}
+
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
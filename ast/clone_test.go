@@ -0,0 +1,73 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	in := []byte(`// doc
+{
+  // a
+  "a": [1, "two", /* three */ {"b": null}], // c
+  "c": {},
+}
+// end
+`)
+	f, err := Parse(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := string(FmtJsonr(f))
+	c := Clone(f).(*File)
+	if !reflect.DeepEqual(f, c) {
+		t.Fatal("expected an identical copy")
+	}
+	if !Equal(f, c, OptionCompareComments, OptionCompareKeyOrder) {
+		t.Fatal("expected an equal copy")
+	}
+
+	// Nothing is shared with the original or its source.
+	Inspect(c, func(n Node) bool {
+		switch n := n.(type) {
+		case *Literal:
+			n.Value[0] = 'X'
+		case *Key:
+			n.Raw[0] = 'X'
+		case *Comment:
+			n.Text[0] = 'X'
+		}
+		return true
+	})
+	obj := c.Root.(*Object)
+	obj.Set("d", NewBool(true))
+	obj.Fields[0].Value.(*Array).Remove(0)
+	obj.Fields[0].Doc.List = nil
+
+	if out := string(FmtJsonr(f)); out != before {
+		t.Errorf("original changed:\n%s", out)
+	}
+}
+
+func TestCloneNodes(t *testing.T) {
+	if Clone(nil) != nil {
+		t.Error("expected nil")
+	}
+	for _, n := range []Node{
+		NewString("x"),
+		NewKey("x"),
+		&Field{Key: NewKey("x"), Value: NewNull()},
+		&Element{},
+		&Array{},
+		&Object{},
+		&BadValue{Text: []byte("?")},
+		&Comment{Text: []byte("// x")},
+		&CommentGroup{List: []*Comment{{Text: []byte("// x")}}},
+		&File{},
+	} {
+		c := Clone(n)
+		if c == n || !reflect.DeepEqual(c, n) {
+			t.Errorf("%T: expected an identical copy, got %#v", n, c)
+		}
+	}
+}
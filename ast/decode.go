@@ -0,0 +1,1036 @@
+package ast
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Unmarshal decodes JSONR directly into v with the same semantics as
+// json.Unmarshal, without first stripping comments into a second
+// buffer. Types implementing json.Unmarshaler receive their value as
+// stripped JSON.
+func Unmarshal(data []byte, v interface{}) error {
+	return unmarshalDialect(data, v, 0)
+}
+
+func unmarshalDialect(data []byte, v interface{}, dialect Dialect) error {
+	data, err := sourceUTF8(data, false)
+	if err != nil {
+		return err
+	}
+	return unmarshalUTF8(data, v, dialect)
+}
+
+// unmarshalUTF8 is unmarshalDialect for data that has already been
+// through sourceUTF8.
+func unmarshalUTF8(data []byte, v interface{}, dialect Dialect) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &json.InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+	d := &decodeState{scan: scanner{input: data, dialect: dialect, noLeadingZeros: true}}
+	return d.unmarshal(rv)
+}
+
+// UnmarshalStrict is like Unmarshal, but apart from comments and
+// trailing commas accepts only what RFC 8259 allows; see StripStrict.
+func UnmarshalStrict(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &json.InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+	data, err := sourceUTF8(data, true)
+	if err != nil {
+		return err
+	}
+	d := &decodeState{scan: scanner{input: data, strict: true}}
+	return d.unmarshal(rv)
+}
+
+type decodeState struct {
+	scan  scanner
+	tok   token
+	saved error // first type error, reported once decoding completes
+
+	// Context for type errors. errorFields is a stack of field names
+	// that is reused as decoding descends into structs.
+	errorStruct reflect.Type
+	errorFields []string
+
+	// The tokens of the input other than whitespace and comments. The
+	// pass that checks the input records them so that the pass that
+	// decodes it can replay them rather than scan the input again.
+	tokens []token
+	record bool
+	replay bool
+	ntok   int // the next token to replay
+}
+
+var tokenPool = sync.Pool{
+	New: func() interface{} { return new([]token) },
+}
+
+// maxPooledTokens bounds the token buffers kept for reuse, so that one
+// huge document does not pin its buffer.
+const maxPooledTokens = 1 << 16
+
+func (d *decodeState) unmarshal(rv reflect.Value) error {
+	// Like json.Unmarshal, check the whole input before touching rv so
+	// that a syntax error leaves it unchanged.
+	buf := tokenPool.Get().(*[]token)
+	defer func() {
+		if cap(*buf) <= maxPooledTokens {
+			tokenPool.Put(buf)
+		}
+	}()
+	check := &decodeState{scan: d.scan, tokens: (*buf)[:0], record: true}
+	err := check.document(reflect.Value{})
+	*buf = check.tokens
+	if err != nil {
+		return err
+	}
+	d.tokens, d.replay = check.tokens, true
+	return d.document(rv)
+}
+
+// document decodes a single top-level value into v, or only checks
+// its syntax if v is invalid.
+func (d *decodeState) document(v reflect.Value) error {
+	if err := d.next(); err != nil {
+		return err
+	}
+	if v.IsValid() {
+		if err := d.value(v); err != nil {
+			return err
+		}
+	} else if err := d.skip(); err != nil {
+		return err
+	}
+	if err := d.next(); err != nil {
+		return err
+	}
+	if d.tok.typ != itemEOF {
+		return d.syntaxError("invalid character after top-level value")
+	}
+	return d.saved
+}
+
+// next advances to the next token that is not whitespace or a comment.
+func (d *decodeState) next() error {
+	if d.replay {
+		d.tok = d.tokens[d.ntok]
+		d.ntok++
+		return nil
+	}
+	return d.scanNext()
+}
+
+// scanNext is next for input that has not been checked yet.
+func (d *decodeState) scanNext() error {
+	for {
+		// Skip the common whitespace here rather than scan it as a
+		// token of its own.
+		for d.scan.pos < len(d.scan.input) && isSpace(d.scan.input[d.scan.pos]) {
+			d.scan.pos++
+		}
+		d.scan.scan(&d.tok)
+		switch d.tok.typ {
+		case itemWhitespace, itemComment:
+			continue
+		case itemError:
+			return d.scan.err
+		}
+		if d.record {
+			d.tokens = append(d.tokens, d.tok)
+		}
+		return nil
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (d *decodeState) syntaxError(msg string) error {
+	if d.tok.typ == itemEOF {
+		return &SyntaxError{Msg: "unexpected EOF", Offset: d.tok.start}
+	}
+	return &SyntaxError{Msg: fmt.Sprintf("%s: %s", msg, excerpt(d.scan.text(&d.tok))), Offset: d.tok.start}
+}
+
+// saveError records the first type error.
+func (d *decodeState) saveError(err error) {
+	if d.saved == nil {
+		d.saved = err
+	}
+}
+
+// typeError records a type error for the current token. Like
+// encoding/json, the offset is just past the token.
+func (d *decodeState) typeError(what string, t reflect.Type) {
+	d.typeErrorAt(what, t, d.tok.end)
+}
+
+func (d *decodeState) typeErrorAt(what string, t reflect.Type, offset int) {
+	err := &json.UnmarshalTypeError{Value: what, Type: t, Offset: int64(offset)}
+	if d.errorStruct != nil {
+		err.Struct = d.errorStruct.Name()
+		err.Field = strings.Join(d.errorFields, ".")
+	}
+	d.saveError(err)
+}
+
+// value decodes the value starting at the current token into v. An
+// invalid v discards the value. On return the current token is the
+// last token of the value.
+func (d *decodeState) value(v reflect.Value) error {
+	switch d.tok.typ {
+	case itemObjectOpen:
+		if !v.IsValid() {
+			return d.skip()
+		}
+		return d.object(v)
+	case itemArrayOpen:
+		if !v.IsValid() {
+			return d.skip()
+		}
+		return d.array(v)
+	case itemString, itemNumber, itemTrue, itemFalse, itemNull:
+		if !v.IsValid() {
+			return nil
+		}
+		typ, item := d.literalToken()
+		return d.literal(typ, item, v, false)
+	}
+	return d.syntaxError("unexpected token")
+}
+
+// literalToken returns the type and text of the current literal token.
+// Numbers in a Dialect are rewritten as JSON, and Infinity and NaN,
+// which JSON lacks, decode as null as they do after StripDialect.
+func (d *decodeState) literalToken() (itemType, []byte) {
+	typ, item := d.tok.typ, d.scan.text(&d.tok)
+	if typ == itemNumber && d.scan.dialect != 0 {
+		if item = jsonNumber(item); item[0] == 'n' {
+			typ = itemNull
+		}
+	}
+	return typ, item
+}
+
+// skip validates and discards the value starting at the current token.
+func (d *decodeState) skip() error {
+	switch d.tok.typ {
+	case itemObjectOpen:
+		for {
+			if err := d.next(); err != nil {
+				return err
+			}
+			if d.tok.typ == itemObjectClose {
+				return nil
+			}
+			if err := d.objectKey(); err != nil {
+				return err
+			}
+			if err := d.skip(); err != nil {
+				return err
+			}
+			if done, err := d.afterMember(itemObjectClose); done || err != nil {
+				return err
+			}
+		}
+	case itemArrayOpen:
+		for {
+			if err := d.next(); err != nil {
+				return err
+			}
+			if d.tok.typ == itemArrayClose {
+				return nil
+			}
+			if err := d.skip(); err != nil {
+				return err
+			}
+			if done, err := d.afterMember(itemArrayClose); done || err != nil {
+				return err
+			}
+		}
+	case itemString, itemNumber, itemTrue, itemFalse, itemNull:
+		return nil
+	}
+	return d.syntaxError("unexpected token")
+}
+
+// objectKey checks that the current token is a key followed by a colon
+// and advances to the start of the value.
+func (d *decodeState) objectKey() error {
+	if _, err := d.key(); err != nil {
+		return err
+	}
+	return d.colon()
+}
+
+// key decodes the current token as an object key, which may be an
+// identifier in a Dialect.
+func (d *decodeState) key() ([]byte, error) {
+	if d.tok.typ != itemString && !d.scan.isIdentKey(&d.tok) {
+		return nil, d.syntaxError("invalid key token")
+	}
+	key, ok := d.unquote(d.scan.text(&d.tok), false)
+	if !ok {
+		return nil, d.syntaxError("invalid key")
+	}
+	return key, nil
+}
+
+// unquote returns the content of item, a string or key. Unless item
+// is the content of a ",string" field, it is the current token, and
+// the content of a plain string is used as is.
+func (d *decodeState) unquote(item []byte, fromQuoted bool) ([]byte, bool) {
+	if !fromQuoted && d.tok.plain {
+		return item[1 : len(item)-1], true
+	}
+	return unquoteKey(item)
+}
+
+// colon consumes the colon after a key and advances to the start of
+// the value.
+func (d *decodeState) colon() error {
+	if err := d.next(); err != nil {
+		return err
+	}
+	if d.tok.typ != itemColon {
+		return d.syntaxError("expected colon delimiter for key token")
+	}
+	return d.next()
+}
+
+// afterMember consumes the delimiter after an object member or array
+// element. It reports true when the container has been closed. A
+// trailing comma before the close is permitted.
+func (d *decodeState) afterMember(close itemType) (bool, error) {
+	if err := d.next(); err != nil {
+		return false, err
+	}
+	switch d.tok.typ {
+	case close:
+		return true, nil
+	case itemComma:
+		return false, nil
+	}
+	return false, d.syntaxError("expected comma or close")
+}
+
+// raw returns the stripped JSON of the value starting at the current
+// token, for a json.Unmarshaler.
+func (d *decodeState) raw() ([]byte, error) {
+	start := d.tok.start
+	if err := d.skip(); err != nil {
+		return nil, err
+	}
+	return (&stripper{dialect: d.scan.dialect}).strip(d.scan.input[start:d.tok.end])
+}
+
+// indirect walks down v allocating pointers as needed, until it gets
+// to a non-pointer. If it encounters an Unmarshaler, indirect stops and
+// returns that. If decodingNull is true, indirect stops at the first
+// settable pointer so it can be set to nil.
+func indirect(v reflect.Value, decodingNull bool) (json.Unmarshaler, encoding.TextUnmarshaler, reflect.Value) {
+	v0 := v
+	haveAddr := false
+
+	// If v is a named type and is addressable, start with its address,
+	// so that if the type has pointer methods, we find them.
+	if v.Kind() != reflect.Ptr && v.Type().Name() != "" && v.CanAddr() {
+		haveAddr = true
+		v = v.Addr()
+	}
+	for {
+		// Load value from interface, but only if the result will be
+		// usefully addressable.
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			e := v.Elem()
+			if e.Kind() == reflect.Ptr && !e.IsNil() && (!decodingNull || e.Elem().Kind() == reflect.Ptr) {
+				haveAddr = false
+				v = e
+				continue
+			}
+		}
+
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+
+		if decodingNull && v.CanSet() {
+			break
+		}
+
+		// Prevent infinite loop if v is an interface pointing to its own address:
+		//     var v interface{}
+		//     v = &v
+		if v.Elem().Kind() == reflect.Interface && v.Elem().Elem() == v {
+			v = v.Elem()
+			break
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		if v.Type().NumMethod() > 0 && v.CanInterface() {
+			if u, ok := v.Interface().(json.Unmarshaler); ok {
+				return u, nil, reflect.Value{}
+			}
+			if !decodingNull {
+				if u, ok := v.Interface().(encoding.TextUnmarshaler); ok {
+					return nil, u, reflect.Value{}
+				}
+			}
+		}
+
+		if haveAddr {
+			v = v0 // restore original value after round-trip Value.Addr().Elem()
+			haveAddr = false
+		} else {
+			v = v.Elem()
+		}
+	}
+	return nil, nil, v
+}
+
+func (d *decodeState) array(v reflect.Value) error {
+	u, ut, pv := indirect(v, false)
+	if u != nil {
+		raw, err := d.raw()
+		if err != nil {
+			return err
+		}
+		return u.UnmarshalJSON(raw)
+	}
+	if ut != nil {
+		d.typeError("array", v.Type())
+		return d.skip()
+	}
+	v = pv
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			ai, err := d.arrayInterface()
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(ai))
+			return nil
+		}
+		fallthrough
+	default:
+		d.typeError("array", v.Type())
+		return d.skip()
+	case reflect.Array, reflect.Slice:
+	}
+
+	i := 0
+	for {
+		if err := d.next(); err != nil {
+			return err
+		}
+		if d.tok.typ == itemArrayClose {
+			break
+		}
+
+		if v.Kind() == reflect.Slice {
+			if i >= v.Cap() {
+				newcap := v.Cap() + v.Cap()/2
+				if newcap < 4 {
+					newcap = 4
+				}
+				newv := reflect.MakeSlice(v.Type(), v.Len(), newcap)
+				reflect.Copy(newv, v)
+				v.Set(newv)
+			}
+			if i >= v.Len() {
+				v.SetLen(i + 1)
+			}
+		}
+
+		var err error
+		if i < v.Len() {
+			err = d.value(v.Index(i))
+		} else {
+			// Ran out of fixed array: skip.
+			err = d.value(reflect.Value{})
+		}
+		if err != nil {
+			return err
+		}
+		i++
+
+		if done, err := d.afterMember(itemArrayClose); err != nil {
+			return err
+		} else if done {
+			break
+		}
+	}
+
+	if i < v.Len() {
+		if v.Kind() == reflect.Array {
+			// Array. Zero the rest.
+			z := reflect.Zero(v.Type().Elem())
+			for ; i < v.Len(); i++ {
+				v.Index(i).Set(z)
+			}
+		} else {
+			v.SetLen(i)
+		}
+	}
+	if i == 0 && v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+	}
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func (d *decodeState) object(v reflect.Value) error {
+	u, ut, pv := indirect(v, false)
+	if u != nil {
+		raw, err := d.raw()
+		if err != nil {
+			return err
+		}
+		return u.UnmarshalJSON(raw)
+	}
+	if ut != nil {
+		d.typeError("object", v.Type())
+		return d.skip()
+	}
+	v = pv
+	t := v.Type()
+
+	// Decoding into nil interface? Switch to non-reflect code.
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		oi, err := d.objectInterface()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(oi))
+		return nil
+	}
+
+	var fields *decodeFields
+	switch v.Kind() {
+	case reflect.Map:
+		// Map key must either have string kind, have an integer kind,
+		// or be an encoding.TextUnmarshaler.
+		switch t.Key().Kind() {
+		case reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			if !reflect.PtrTo(t.Key()).Implements(textUnmarshalerType) {
+				d.typeError("object", t)
+				return d.skip()
+			}
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+	case reflect.Struct:
+		fields = cachedDecodeFields(t)
+	default:
+		d.typeError("object", t)
+		return d.skip()
+	}
+
+	var mapElem, mapKeyElem reflect.Value
+	origStruct, origDepth := d.errorStruct, len(d.errorFields)
+	nextField := 0
+
+	for {
+		if err := d.next(); err != nil {
+			return err
+		}
+		if d.tok.typ == itemObjectClose {
+			break
+		}
+		key, err := d.key()
+		if err != nil {
+			return err
+		}
+		keyStart := d.tok.start
+
+		// Figure out field corresponding to key.
+		var subv reflect.Value
+		quoted := false
+		if v.Kind() == reflect.Map {
+			elemType := t.Elem()
+			if !mapElem.IsValid() {
+				mapElem = reflect.New(elemType).Elem()
+			} else {
+				mapElem.Set(reflect.Zero(elemType))
+			}
+			subv = mapElem
+		} else if i := fields.lookup(key, nextField); i >= 0 {
+			f := &fields.list[i]
+			nextField = i + 1
+			subv = v
+			quoted = f.quoted
+			for _, ind := range f.index {
+				if subv.Kind() == reflect.Ptr {
+					if subv.IsNil() {
+						// If a struct embeds a pointer to an unexported type,
+						// it is not possible to set a newly allocated value
+						// since the field is unexported.
+						if !subv.CanSet() {
+							d.saveError(fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v", subv.Type().Elem()))
+							// Invalidate subv to ensure d.value(subv) skips over
+							// the JSON value without assigning it to subv.
+							subv = reflect.Value{}
+							break
+						}
+						subv.Set(reflect.New(subv.Type().Elem()))
+					}
+					subv = subv.Elem()
+				}
+				subv = subv.Field(ind)
+			}
+			d.errorStruct = t
+			d.errorFields = append(d.errorFields[:origDepth], f.name)
+		}
+
+		if err := d.colon(); err != nil {
+			return err
+		}
+
+		if quoted && subv.IsValid() {
+			err = d.quotedValue(subv)
+		} else {
+			err = d.value(subv)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Write value back to map; if using struct, subv points into
+		// struct already.
+		if v.Kind() == reflect.Map && t.Key().Kind() == reflect.String &&
+			!reflect.PtrTo(t.Key()).Implements(textUnmarshalerType) {
+			// SetMapIndex copies the key, so a single holder can be
+			// reused to avoid boxing every key.
+			if !mapKeyElem.IsValid() {
+				mapKeyElem = reflect.New(t.Key()).Elem()
+			}
+			mapKeyElem.SetString(string(key))
+			v.SetMapIndex(mapKeyElem, subv)
+		} else if v.Kind() == reflect.Map {
+			kv, err := mapKey(t.Key(), key)
+			if err != nil && reflect.PtrTo(t.Key()).Implements(textUnmarshalerType) {
+				return err
+			} else if err != nil {
+				d.typeErrorAt("number "+string(key), t.Key(), keyStart+1)
+			} else if kv.IsValid() {
+				v.SetMapIndex(kv, subv)
+			}
+		}
+		d.errorStruct, d.errorFields = origStruct, d.errorFields[:origDepth]
+
+		if done, err := d.afterMember(itemObjectClose); err != nil {
+			return err
+		} else if done {
+			break
+		}
+	}
+	return nil
+}
+
+func mapKey(kt reflect.Type, key []byte) (reflect.Value, error) {
+	switch {
+	case reflect.PtrTo(kt).Implements(textUnmarshalerType):
+		kv := reflect.New(kt)
+		if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText(key); err != nil {
+			return reflect.Value{}, err
+		}
+		return kv.Elem(), nil
+	case kt.Kind() == reflect.String:
+		kv := reflect.ValueOf(string(key))
+		if kt != kv.Type() {
+			kv = kv.Convert(kt)
+		}
+		return kv, nil
+	}
+	switch kt.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(string(key), 10, 64)
+		if err != nil || reflect.Zero(kt).OverflowInt(n) {
+			return reflect.Value{}, fmt.Errorf("invalid key")
+		}
+		return reflect.ValueOf(n).Convert(kt), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(string(key), 10, 64)
+		if err != nil || reflect.Zero(kt).OverflowUint(n) {
+			return reflect.Value{}, fmt.Errorf("invalid key")
+		}
+		return reflect.ValueOf(n).Convert(kt), nil
+	}
+	panic("json: Unexpected key type") // should never occur
+}
+
+// quotedValue decodes a value for a field with the ",string" option.
+// As with encoding/json, the string's content is decoded as the
+// literal it starts like, and errors quote the content.
+func (d *decodeState) quotedValue(v reflect.Value) error {
+	switch d.tok.typ {
+	case itemNull:
+		return d.literal(itemNull, d.scan.text(&d.tok), v, false)
+	case itemString:
+		s, ok := d.unquote(d.scan.text(&d.tok), false)
+		if !ok {
+			return d.syntaxError("invalid string")
+		}
+		if len(s) == 0 {
+			d.saveError(quotedError(s, v.Type()))
+			return nil
+		}
+		typ := itemNumber
+		switch s[0] {
+		case 'n':
+			typ = itemNull
+		case 't', 'f':
+			typ = itemTrue
+		case '"':
+			typ = itemString
+		}
+		return d.literal(typ, s, v, true)
+	}
+	d.saveError(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal unquoted value into %v", v.Type()))
+	return d.value(reflect.Value{})
+}
+
+var numberType = reflect.TypeOf(json.Number(""))
+
+// literal decodes a string, number, true, false or null into v. item
+// is the literal's source text. fromQuoted is set for the content of a
+// ",string" field.
+func (d *decodeState) literal(typ itemType, item []byte, v reflect.Value, fromQuoted bool) error {
+	isNull := typ == itemNull
+	u, ut, pv := indirect(v, isNull)
+	if u != nil {
+		if typ == itemString && (d.scan.dialect != 0 || isTextBlock(item)) {
+			// As StripDialect would.
+			item = jsonString(item)
+		}
+		return u.UnmarshalJSON(item)
+	}
+	if ut != nil {
+		if typ != itemString {
+			if fromQuoted {
+				d.saveError(quotedError(item, v.Type()))
+				return nil
+			}
+			d.typeError(literalName(typ), v.Type())
+			return nil
+		}
+		s, ok := d.unquote(item, fromQuoted)
+		if !ok {
+			if fromQuoted {
+				return quotedError(item, v.Type())
+			}
+			return d.syntaxError("invalid string")
+		}
+		return ut.UnmarshalText(s)
+	}
+	v = pv
+
+	switch typ {
+	case itemNull:
+		if fromQuoted && string(item) != "null" {
+			d.saveError(quotedError(item, v.Type()))
+			break
+		}
+		switch v.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+			// otherwise, ignore null for primitives/string
+		}
+	case itemTrue, itemFalse:
+		value := item[0] == 't'
+		if fromQuoted && string(item) != "true" && string(item) != "false" {
+			d.saveError(quotedError(item, v.Type()))
+			break
+		}
+		switch v.Kind() {
+		default:
+			if fromQuoted {
+				d.saveError(quotedError(item, v.Type()))
+				break
+			}
+			d.typeError("bool", v.Type())
+		case reflect.Bool:
+			v.SetBool(value)
+		case reflect.Interface:
+			if v.NumMethod() == 0 {
+				v.Set(reflect.ValueOf(value))
+			} else {
+				d.typeError("bool", v.Type())
+			}
+		}
+	case itemString:
+		s, ok := d.unquote(item, fromQuoted)
+		if !ok {
+			if fromQuoted {
+				return quotedError(item, v.Type())
+			}
+			return d.syntaxError("invalid string")
+		}
+		switch v.Kind() {
+		default:
+			d.typeError("string", v.Type())
+		case reflect.Slice:
+			if v.Type().Elem().Kind() != reflect.Uint8 {
+				d.typeError("string", v.Type())
+				break
+			}
+			b := make([]byte, base64.StdEncoding.DecodedLen(len(s)))
+			n, err := base64.StdEncoding.Decode(b, s)
+			if err != nil {
+				d.saveError(err)
+				break
+			}
+			v.SetBytes(b[:n])
+		case reflect.String:
+			if v.Type() == numberType && !isValidNumber(s) {
+				return fmt.Errorf("json: invalid number literal, trying to unmarshal %q into Number", item)
+			}
+			v.SetString(string(s))
+		case reflect.Interface:
+			if v.NumMethod() == 0 {
+				v.Set(reflect.ValueOf(string(s)))
+			} else {
+				d.typeError("string", v.Type())
+			}
+		}
+	case itemNumber:
+		if c := item[0]; fromQuoted && c != '-' && (c < '0' || c > '9') {
+			return quotedError(item, v.Type())
+		}
+		switch v.Kind() {
+		default:
+			if v.Kind() == reflect.String && v.Type() == numberType {
+				// s must be a valid number, because it's
+				// already been tokenized.
+				v.SetString(string(item))
+				break
+			}
+			if fromQuoted {
+				return quotedError(item, v.Type())
+			}
+			d.typeError("number", v.Type())
+		case reflect.Interface:
+			n, err := strconv.ParseFloat(string(item), 64)
+			if err != nil {
+				d.numberError(item)
+				break
+			}
+			if v.NumMethod() != 0 {
+				d.typeError("number", v.Type())
+				break
+			}
+			v.Set(reflect.ValueOf(n))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(string(item), 10, 64)
+			if err != nil || v.OverflowInt(n) {
+				d.typeError("number "+string(item), v.Type())
+				break
+			}
+			v.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			n, err := strconv.ParseUint(string(item), 10, 64)
+			if err != nil || v.OverflowUint(n) {
+				d.typeError("number "+string(item), v.Type())
+				break
+			}
+			v.SetUint(n)
+		case reflect.Float32, reflect.Float64:
+			n, err := strconv.ParseFloat(string(item), v.Type().Bits())
+			if err != nil || v.OverflowFloat(n) {
+				d.typeError("number "+string(item), v.Type())
+				break
+			}
+			v.SetFloat(n)
+		}
+	}
+	return nil
+}
+
+var float64Type = reflect.TypeOf(float64(0))
+
+// numberError records a number that does not fit an interface value.
+// encoding/json reports these one byte further on than other type
+// errors.
+func (d *decodeState) numberError(item []byte) {
+	d.typeErrorAt("number "+string(item), float64Type, d.tok.end+1)
+}
+
+// quotedError reports the content of a ",string" field that does not
+// suit its type.
+func quotedError(item []byte, t reflect.Type) error {
+	return fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", item, t)
+}
+
+func literalName(typ itemType) string {
+	switch typ {
+	case itemString:
+		return "string"
+	case itemNumber:
+		return "number"
+	case itemTrue, itemFalse:
+		return "bool"
+	}
+	return "null"
+}
+
+func isValidNumber(s []byte) bool {
+	if len(s) == 0 {
+		return false
+	}
+	sc := scanner{input: s, noLeadingZeros: true}
+	var t token
+	sc.scan(&t)
+	return t.typ == itemNumber && sc.pos == len(s)
+}
+
+// The xxxInterface routines build up a value to be stored in an empty
+// interface. They are not strictly necessary, but they avoid the
+// weight of reflection in this common case.
+
+func (d *decodeState) valueInterface() (interface{}, error) {
+	switch d.tok.typ {
+	case itemObjectOpen:
+		return d.objectInterface()
+	case itemArrayOpen:
+		return d.arrayInterface()
+	case itemString:
+		s, ok := d.unquote(d.scan.text(&d.tok), false)
+		if !ok {
+			return nil, d.syntaxError("invalid string")
+		}
+		return string(s), nil
+	case itemNumber:
+		typ, item := d.literalToken()
+		if typ == itemNull {
+			return nil, nil
+		}
+		n, err := strconv.ParseFloat(string(item), 64)
+		if err != nil {
+			d.numberError(item)
+			return nil, nil
+		}
+		return n, nil
+	case itemTrue:
+		return true, nil
+	case itemFalse:
+		return false, nil
+	case itemNull:
+		return nil, nil
+	}
+	return nil, d.syntaxError("unexpected token")
+}
+
+func (d *decodeState) arrayInterface() ([]interface{}, error) {
+	var v = make([]interface{}, 0)
+	for {
+		if err := d.next(); err != nil {
+			return nil, err
+		}
+		if d.tok.typ == itemArrayClose {
+			return v, nil
+		}
+		x, err := d.valueInterface()
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, x)
+		if done, err := d.afterMember(itemArrayClose); err != nil {
+			return nil, err
+		} else if done {
+			return v, nil
+		}
+	}
+}
+
+func (d *decodeState) objectInterface() (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for {
+		if err := d.next(); err != nil {
+			return nil, err
+		}
+		if d.tok.typ == itemObjectClose {
+			return m, nil
+		}
+		key, err := d.key()
+		if err != nil {
+			return nil, err
+		}
+		if err := d.colon(); err != nil {
+			return nil, err
+		}
+		x, err := d.valueInterface()
+		if err != nil {
+			return nil, err
+		}
+		m[string(key)] = x
+		if done, err := d.afterMember(itemObjectClose); err != nil {
+			return nil, err
+		} else if done {
+			return m, nil
+		}
+	}
+}
+
+// decodeFields holds the fields of a struct type for decoding. Keys
+// match a field name exactly or, failing that, case-insensitively.
+type decodeFields struct {
+	list  []structField
+	names [][]byte // the names in list, to compare keys without converting them
+}
+
+var decodeFieldCache sync.Map // map[reflect.Type]*decodeFields
+
+func cachedDecodeFields(t reflect.Type) *decodeFields {
+	if f, ok := decodeFieldCache.Load(t); ok {
+		return f.(*decodeFields)
+	}
+	fields := &decodeFields{list: cachedTypeFields(t)}
+	for _, f := range fields.list {
+		fields.names = append(fields.names, []byte(f.name))
+	}
+	f, _ := decodeFieldCache.LoadOrStore(t, fields)
+	return f.(*decodeFields)
+}
+
+// lookup returns the index of the field for key, or -1. Keys usually
+// follow the order of the fields, so the search starts at hint, the
+// field after the last one found; that is cheaper than hashing the key.
+func (fs *decodeFields) lookup(key []byte, hint int) int {
+	n := len(fs.names)
+	for j := 0; j < n; j++ {
+		i := hint + j
+		if i >= n {
+			i -= n
+		}
+		if bytes.Equal(fs.names[i], key) {
+			return i
+		}
+	}
+	for i, name := range fs.names {
+		if bytes.EqualFold(name, key) {
+			return i
+		}
+	}
+	return -1
+}
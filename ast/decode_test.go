@@ -0,0 +1,255 @@
+package ast
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type decodeInner struct {
+	Name  string `json:"name"`
+	Count int
+}
+
+type decodeEmbedded struct {
+	Embedded string
+}
+
+type decodeTarget struct {
+	decodeEmbedded
+	S       string                 `json:"s"`
+	I       int8                   `json:"i"`
+	U       uint                   `json:"u"`
+	F       float32                `json:"f"`
+	B       bool                   `json:"b"`
+	P       *decodeInner           `json:"p"`
+	Inner   decodeInner            `json:"inner"`
+	List    []int                  `json:"list"`
+	Fixed   [2]string              `json:"fixed"`
+	Map     map[string]interface{} `json:"map"`
+	IntMap  map[int]string         `json:"int_map"`
+	Any     interface{}            `json:"any"`
+	Bytes   []byte                 `json:"bytes"`
+	Quoted  int                    `json:"quoted,string"`
+	QPtr    *int                   `json:"qptr,omitempty,string"`
+	NotQ    int                    `json:"notq,stringify"`
+	Number  json.Number            `json:"number"`
+	Raw     json.RawMessage        `json:"raw"`
+	Addr    net.IP                 `json:"addr"`
+	When    time.Time              `json:"when"`
+	Skipped string                 `json:"-"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	checkDecode := func(in string, newTarget func() interface{}) {
+		stripped, err := Strip([]byte(in))
+		if err != nil {
+			t.Fatalf("strip failed: %s: %s", in, err)
+		}
+		want := newTarget()
+		wantErr := json.Unmarshal(stripped, want)
+		got := newTarget()
+		gotErr := Unmarshal([]byte(in), got)
+		if (wantErr == nil) != (gotErr == nil) {
+			t.Fatalf("input %s: expected err %v, got %v", in, wantErr, gotErr)
+		}
+		if wantErr != nil && reflect.TypeOf(wantErr) != reflect.TypeOf(gotErr) {
+			t.Fatalf("input %s: expected err %T %v, got %T %v", in, wantErr, wantErr, gotErr, gotErr)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("input %s:\nexpected: %s\ngot: %s", in, prettyFmt(want), prettyFmt(got))
+		}
+	}
+	newAny := func() interface{} { return new(interface{}) }
+	newTarget := func() interface{} { return &decodeTarget{Skipped: "keep", List: []int{9, 9, 9}} }
+
+	for _, in := range []string{
+		`null`, `true`, `false`, `"str\n"`, `-1.5e3`, `[]`, `{}`,
+		`[1, "two", [null], {"three": 3},]`,
+		`/* doc */ {"a": {"b": [1, 2, 3]}, // trailer
+		}`,
+		`{"a": 1, "a": 2}`,
+	} {
+		checkDecode(in, newAny)
+	}
+
+	checkDecode(`{
+  // Leading comment.
+  "Embedded": "e",
+  "s": "string",
+  "i": 127,
+  "u": 7,
+  "f": 1.5,
+  "b": true,
+  "p": {"name": "pointer", "count": 2},
+  "inner": {"NAME": "folded", "Count": 3, "unknown": [1, {"x": null}]},
+  "list": [1, 2,],
+  "fixed": ["one", "two", "three"],
+  "map": {"x": [1, "y"], "z": null},
+  "int_map": {"1": "one", "-2": "minus two"},
+  "any": {"nested": [true]},
+  "bytes": "aGVsbG8=",
+  "quoted": "42",
+  "qptr": "7",
+  "notq": 3,
+  "number": 1.50,
+  "raw": {"keep": [1, 2], /* stripped */},
+  "addr": "10.0.0.1",
+  "when": "2020-01-02T03:04:05Z",
+  "Skipped": "ignored",
+}`, newTarget)
+
+	checkDecode(`{"list": [], "fixed": ["one"], "p": null, "map": null}`, newTarget)
+
+	// Type errors are reported after decoding the rest.
+	checkDecode(`{"i": 1000, "s": "after"}`, newTarget)
+	checkDecode(`{"s": 1, "inner": {"name": []}, "list": {}, "b": "true"}`, newTarget)
+	checkDecode(`{"int_map": {"x": "nan"}}`, newTarget)
+	checkDecode(`{"quoted": "012", "qptr": "-3"}`, newTarget)
+	// Leading zeros are rejected in skipped values too, as they are by
+	// encoding/json.
+	var serr *SyntaxError
+	if err := Unmarshal([]byte(`{"unknown": [012]}`), &decodeTarget{}); !errors.As(err, &serr) {
+		t.Errorf("expected syntax error for a leading zero, got %v", err)
+	}
+
+	// Syntax errors.
+	for _, in := range []string{
+		``, `{`, `[1 2]`, `{"a" 1}`, `{"a": 1 "b": 2}`, `[,]`, `{,}`, `nul`,
+		`{"a": [1, 2}`, `"unterminated`, `1 2`, `{"a": 1}}`, `/* open`, `{"a": 01x}`,
+		"\"ctl\x01\"", `[012, 1]`, `-01.5`, `{"a": 00}`,
+	} {
+		err := Unmarshal([]byte(in), new(interface{}))
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("input %q: expected syntax error, got %v", in, err)
+		}
+	}
+
+	// As with json.Unmarshal, a syntax error anywhere leaves v untouched.
+	untouched := decodeTarget{S: "keep", List: []int{9}}
+	got := untouched
+	if err := Unmarshal([]byte(`{"s": "changed", "list": [1, 2], "i": }`), &got); !errors.As(err, &serr) {
+		t.Errorf("expected syntax error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, untouched) {
+		t.Errorf("expected target to be unchanged, got %s", prettyFmt(got))
+	}
+
+	var s string
+	if err := Unmarshal([]byte(`"\u00e9\ud83d\ude00"`), &s); err != nil || s != "é😀" {
+		t.Errorf("expected unicode escapes to decode, got %q %v", s, err)
+	}
+
+	var notPtr map[string]interface{}
+	var invalid *json.InvalidUnmarshalError
+	if err := Unmarshal([]byte(`{}`), notPtr); !errors.As(err, &invalid) {
+		t.Errorf("expected InvalidUnmarshalError, got %v", err)
+	}
+}
+
+type decodeKey struct{ A int }
+
+func TestUnmarshalErrors(t *testing.T) {
+	// The errors encoding/json reports for the same input. They are
+	// spelled out rather than compared with json.Unmarshal, whose
+	// wording differs under the jsonv2 experiment.
+	stringTag := func(what string, typ interface{}) error {
+		return fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %s into %v", what, reflect.TypeOf(typ))
+	}
+	typeError := func(value string, typ interface{}, offset int64, field string) error {
+		err := &json.UnmarshalTypeError{Value: value, Type: reflect.TypeOf(typ), Offset: offset, Field: field}
+		if field != "" {
+			err.Struct = "decodeTarget"
+		}
+		return err
+	}
+	for _, tc := range []struct {
+		in       string
+		expected error
+	}{
+		{`{"quoted": "nope"}`, stringTag(`"nope"`, 0)},
+		{`{"quoted": "true"}`, stringTag(`"true"`, 0)},
+		{`{"quoted": ""}`, stringTag(`""`, 0)},
+		{`{"quoted": 12}`, stringTag("unquoted value", 0)},
+		{`{"qptr": "x"}`, stringTag(`"x"`, 0)},
+		{`{"quoted": "1.5"}`, typeError("number 1.5", 0, 16, "quoted")},
+		{`{"quoted": "\"1\""}`, typeError("string", 0, 18, "quoted")},
+		{`{"bytes": "!!!"}`, base64.CorruptInputError(0)},
+		{`{"bytes": 1}`, typeError("number", []byte(nil), 11, "bytes")},
+		{`{"int_map": {"x": "a"}}`, typeError("number x", 0, 14, "int_map")},
+		{`{"i": 1000}`, typeError("number 1000", int8(0), 10, "i")},
+		{`{"list": {}}`, typeError("object", []int(nil), 10, "list")},
+		{`{"any": 1e999}`, typeError("number 1e999", 0.0, 14, "any")},
+	} {
+		err := Unmarshal([]byte(tc.in), &decodeTarget{})
+		if !reflect.DeepEqual(err, tc.expected) {
+			t.Errorf("input %s: expected %T %+v, got %T %+v", tc.in, tc.expected, tc.expected, err, err)
+		}
+	}
+
+	// Map keys of an unsupported type.
+	var m map[decodeKey]int
+	expected := &json.UnmarshalTypeError{Value: "object", Type: reflect.TypeOf(m), Offset: 1}
+	if err := Unmarshal([]byte(`{"a": 1}`), &m); !reflect.DeepEqual(err, expected) {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+}
+
+func TestUnmarshalerLiterals(t *testing.T) {
+	// Unmarshalers receive strings as JSON, however they are written.
+	var v struct {
+		Block, Single   time.Time
+		BlockRaw, Raw   json.RawMessage
+		Number, Special json.RawMessage
+	}
+	in := `{
+  Block: """
+    2020-01-01T00:00:00Z""",
+  Single: '2021-01-01T00:00:00Z',
+  BlockRaw: """
+    a "b"
+    """,
+  Raw: ['it\'s', {c: 0x10}],
+  Number: +1_000,
+  Special: NaN,
+}`
+	if err := UnmarshalDialect([]byte(in), &v, DialectJSON5); err != nil {
+		t.Fatal(err)
+	}
+	if v.Block.Year() != 2020 || v.Single.Year() != 2021 {
+		t.Errorf("unexpected times %v and %v", v.Block, v.Single)
+	}
+	for _, tc := range []struct {
+		raw      json.RawMessage
+		expected string
+	}{
+		{v.BlockRaw, `"a \"b\"\n"`},
+		{v.Raw, `["it's",{"c":16}]`},
+		{v.Number, `1000`},
+		{v.Special, `null`},
+	} {
+		if string(tc.raw) != tc.expected {
+			t.Errorf("expected %s, got %s", tc.expected, tc.raw)
+		}
+	}
+
+	var when time.Time
+	if err := Unmarshal([]byte("\"\"\"\n  2020-01-01T00:00:00Z\"\"\""), &when); err != nil {
+		t.Fatal(err)
+	}
+	if when.Year() != 2020 {
+		t.Errorf("unexpected time %v", when)
+	}
+	// A closing """ on its own line ends the string with a newline.
+	err := Unmarshal([]byte("\"\"\"\n  2020-01-01T00:00:00Z\n  \"\"\""), &when)
+	if err == nil || !strings.Contains(err.Error(), "extra text") {
+		t.Errorf("expected a time with a trailing newline to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package ast
+
+import (
+	"bytes"
+	"math/big"
+)
+
+// Dialect is a set of opt-in extensions to JSONR syntax, mostly from
+// JSON5, to ease reading files written for other tools such as VS Code
+// settings. The extended syntax is kept in the AST as written. Strip
+// and FmtJson always normalize it to JSON, as does FmtJsonr with
+// OptionNormalize.
+type Dialect uint
+
+const (
+	DialectIdentifierKeys    Dialect = 1 << iota // {key: 1}
+	DialectSingleQuotes                          // 'string', and \' in any string
+	DialectHashComments                          // # line comment
+	DialectHexNumbers                            // 0xC0FFEE
+	DialectDecimalPoints                         // .5 and 5.
+	DialectPlusSign                              // +1
+	DialectInfinityNaN                           // Infinity, -Infinity and NaN; null in JSON
+	DialectNumericSeparators                     // 1_000_000
+
+	// DialectJSON5 is everything JSON5 allows, except for multi-line
+	// strings, along with hash comments and numeric separators.
+	DialectJSON5 = DialectIdentifierKeys | DialectSingleQuotes | DialectHashComments |
+		DialectHexNumbers | DialectDecimalPoints | DialectPlusSign | DialectInfinityNaN |
+		DialectNumericSeparators
+)
+
+// Accept the extended syntax of the dialect d.
+func OptionDialect(d Dialect) ParseOption {
+	return func(p *astParser) {
+		p.dialect = d
+	}
+}
+
+// StripDialect is like Strip for input in the dialect d, normalizing
+// the extended syntax to JSON.
+func StripDialect(in []byte, d Dialect) ([]byte, error) {
+	return (&stripper{dialect: d}).Strip(in)
+}
+
+// UnmarshalDialect is like Unmarshal for input in the dialect d. Types
+// implementing json.Unmarshaler receive their value as StripDialect
+// would write it.
+func UnmarshalDialect(data []byte, v interface{}, d Dialect) error {
+	return unmarshalDialect(data, v, d)
+}
+
+var (
+	hashComment  = []byte("#")
+	escapedQuote = []byte(`\'`)
+)
+
+// isLineComment reports whether the text of a comment runs to the end
+// of its line.
+func isLineComment(text []byte) bool {
+	return bytes.HasPrefix(text, commentStart) || bytes.HasPrefix(text, hashComment)
+}
+
+// jsonComment returns the text of a comment in JSONR syntax.
+func jsonComment(text []byte) []byte {
+	if !bytes.HasPrefix(text, hashComment) {
+		return text
+	}
+	return append(append([]byte(nil), commentStart...), text[len(hashComment):]...)
+}
+
+// jsonString returns a string literal in JSON syntax. Escapes other
+// than \' are kept as they are, except in multi-line strings, which are
+// quoted anew. It returns s itself if it is already JSON.
+func jsonString(s []byte) []byte {
+	if isTextBlock(s) {
+		v, _ := unquote(s)
+		return quote(v)
+	}
+	if s[0] == '"' && bytes.Index(s, escapedQuote) < 0 {
+		return s
+	}
+	b := make([]byte, 0, len(s)+2)
+	b = append(b, '"')
+	for i := 1; i < len(s)-1; i++ {
+		switch c := s[i]; {
+		case c == '\\' && s[i+1] == '\'':
+			b = append(b, '\'')
+			i++
+		case c == '\\':
+			b = append(b, c, s[i+1])
+			i++
+		case c == '"':
+			b = append(b, '\\', '"')
+		default:
+			b = append(b, c)
+		}
+	}
+	return append(b, '"')
+}
+
+// jsonKey returns an object key in JSON syntax.
+func jsonKey(raw []byte) []byte {
+	if raw[0] == '"' || raw[0] == '\'' {
+		return jsonString(raw)
+	}
+	// Identifiers need no escaping.
+	b := make([]byte, 0, len(raw)+2)
+	b = append(b, '"')
+	b = append(b, raw...)
+	return append(b, '"')
+}
+
+// isIdentKey reports whether t is an unquoted key, supposing a key is
+// expected. Keywords, Infinity and NaN are identifiers there too.
+func (s *scanner) isIdentKey(t *token) bool {
+	if s.dialect&DialectIdentifierKeys == 0 {
+		return false
+	}
+	switch t.typ {
+	case itemIdent, itemTrue, itemFalse, itemNull:
+		return true
+	case itemNumber:
+		c := s.input[t.start]
+		return c == 'I' || c == 'N'
+	}
+	return false
+}
+
+// unquoteKey decodes an object key, which may be an identifier.
+func unquoteKey(raw []byte) ([]byte, bool) {
+	if len(raw) > 0 && raw[0] != '"' && raw[0] != '\'' {
+		return raw, true
+	}
+	return unquoteBytes(raw)
+}
+
+// jsonNumber returns a number literal in JSON syntax. Infinity and NaN
+// have no equivalent and become null, as in JavaScript's
+// JSON.stringify. It returns num itself if it is already JSON.
+func jsonNumber(num []byte) []byte {
+	s := scanner{input: num}
+	if s.scanNumber() == itemNumber && s.pos == len(num) {
+		return num
+	}
+
+	var b []byte
+	switch num[0] {
+	case '-':
+		b = append(b, '-')
+		num = num[1:]
+	case '+':
+		num = num[1:]
+	}
+	if num[0] == 'I' || num[0] == 'N' {
+		return []byte("null")
+	}
+	num = bytes.Replace(num, []byte("_"), nil, -1)
+	if len(num) > 1 && num[0] == '0' && (num[1] == 'x' || num[1] == 'X') {
+		n, _ := new(big.Int).SetString(string(num[2:]), 16)
+		return n.Append(b, 10)
+	}
+	for i, c := range num {
+		if c != '.' {
+			continue
+		}
+		if i == 0 {
+			b = append(b, '0')
+		}
+		b = append(b, num[:i+1]...)
+		if i+1 == len(num) || !isDigit(num[i+1]) {
+			b = append(b, '0')
+		}
+		return append(b, num[i+1:]...)
+	}
+	return append(b, num...)
+}
@@ -0,0 +1,91 @@
+package ast
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const json5Sample = `# settings
+{
+  name: 'it\'s "here"',
+  $x_1: +1_000,
+  'quoted': [0xFF, -0x10, .5, 5., -.5e3, Infinity, -Infinity, NaN],
+  true: null, // c
+}
+`
+
+func TestDialectFmt(t *testing.T) {
+	f, err := Parse([]byte(json5Sample), OptionDialect(DialectJSON5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(FmtJsonr(f)); out != json5Sample {
+		t.Errorf("expected syntax to be preserved:\n%s\ngot:\n%s", json5Sample, out)
+	}
+
+	expected := `// settings
+{
+  "name": "it's \"here\"",
+  "$x_1": 1000,
+  "quoted": [255, -16, 0.5, 5.0, -0.5e3, null, null, null],
+  "true": null, // c
+}
+`
+	if out := string(FmtJsonr(f, OptionNormalize)); out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	expected = `{"name": "it's \"here\"", "$x_1": 1000, "quoted": [255, -16, 0.5, 5.0, -0.5e3, null, null, null], "true": null}`
+	if out := string(FmtJson(f.Root, OptionLineWidth(200))); out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	if name := f.Root.(*Object).Fields[0].Key.Name; name != "name" {
+		t.Errorf("expected key name, got %q", name)
+	}
+}
+
+func TestStripDialect(t *testing.T) {
+	for _, tc := range []struct {
+		dialect      Dialect
+		in, expected string
+	}{
+		{DialectIdentifierKeys, `{a: 1, null: 2, $_b9: 3}`, `{"a":1,"null":2,"$_b9":3}`},
+		{DialectSingleQuotes, `['a"b', "c\'d", '\\']`, `["a\"b","c'd","\\"]`},
+		{DialectHashComments, "# c\n[1, # c\n2]", `[1,2]`},
+		{DialectHexNumbers, `[0x1f, -0XFF, 0x10000000000000000]`, `[31,-255,18446744073709551616]`},
+		{DialectDecimalPoints, `[.5, 5., -.5, 5.e1]`, `[0.5,5.0,-0.5,5.0e1]`},
+		{DialectPlusSign, `[+1, +0.5e+1]`, `[1,0.5e+1]`},
+		{DialectInfinityNaN, `[Infinity, -Infinity, NaN]`, `[null,null,null]`},
+		{DialectNumericSeparators, `[1_000, 1.000_5e1_0]`, `[1000,1.0005e10]`},
+		{DialectHexNumbers | DialectNumericSeparators, `0xFF_FF`, `65535`},
+	} {
+		out, err := StripDialect([]byte(tc.in), tc.dialect)
+		if err != nil {
+			t.Errorf("input %s: %v", tc.in, err)
+		} else if string(out) != tc.expected {
+			t.Errorf("input %s: expected %s, got %s", tc.in, tc.expected, out)
+		}
+		// Decoding directly gives the same value.
+		var expected, v interface{}
+		if err := json.Unmarshal([]byte(tc.expected), &expected); err != nil {
+			t.Fatal(err)
+		}
+		if err := UnmarshalDialect([]byte(tc.in), &v, tc.dialect); err != nil {
+			t.Errorf("input %s: %v", tc.in, err)
+		} else if !reflect.DeepEqual(v, expected) {
+			t.Errorf("input %s: expected %#v, got %#v", tc.in, expected, v)
+		}
+		// Each extension is opt-in.
+		if _, err := Strip([]byte(tc.in)); err == nil {
+			t.Errorf("input %s: expected error without dialect", tc.in)
+		}
+	}
+
+	for _, in := range []string{`[a]`, `1__0`, `1_`, `_1`, `0x`, `.`, `'abc`, `{a b: 1}`, `0x_1`, `"\x"`} {
+		if _, err := StripDialect([]byte(in), DialectJSON5); err == nil {
+			t.Errorf("input %s: expected error", in)
+		}
+	}
+}
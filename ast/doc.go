@@ -0,0 +1,188 @@
+package ast
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// Doc is the documentation of a value, taken from the comments attached
+// to its field or element with their delimiters removed.
+type Doc struct {
+	Doc     string // comments on the lines before the value
+	Comment string // comment after the value on the same line
+}
+
+// String returns the doc comments followed by the trailing comment.
+func (d Doc) String() string {
+	if d.Doc == "" || d.Comment == "" {
+		return d.Doc + d.Comment
+	}
+	return d.Doc + "\n" + d.Comment
+}
+
+// DocFor returns the documentation of the value at path, a key path as
+// written by FmtKeyAsPath such as "/server/ports/0", or "" if there is
+// no such value or it has no comments. The path "/" refers to the root
+// value, which is documented by the comments at the start of the file.
+func DocFor(f *File, path string) string {
+	if path == "/" {
+		return commentText(f.Doc)
+	}
+	if !strings.HasPrefix(path, "/") {
+		return ""
+	}
+	var doc, comment *CommentGroup
+	v := f.Root
+	for _, step := range splitKeyPath(path[1:]) {
+		switch tv := v.(type) {
+		case *Object:
+			fl := tv.Get(step)
+			if fl == nil {
+				return ""
+			}
+			v, doc, comment = fl.Value, fl.Doc, fl.Comment
+		case *Array:
+			i, err := strconv.Atoi(step)
+			if err != nil || i < 0 || i >= len(tv.Elements) {
+				return ""
+			}
+			e := tv.Elements[i]
+			v, doc, comment = e.Value, e.Doc, e.Comment
+		default:
+			return ""
+		}
+	}
+	return Doc{commentText(doc), commentText(comment)}.String()
+}
+
+// AllDocs returns the documentation of every value in f that has any,
+// by key path as written by FmtKeyAsPath. If a key appears more than
+// once the last field wins, as it does when decoding.
+func AllDocs(f *File) map[string]Doc {
+	docs := make(map[string]Doc)
+	if text := commentText(f.Doc); text != "" {
+		docs["/"] = Doc{Doc: text}
+	}
+	var keyPath []KeyStep
+	var walk func(v Value)
+	add := func(doc, comment *CommentGroup) {
+		path := FmtKeyAsPath(keyPath)
+		d := Doc{commentText(doc), commentText(comment)}
+		if d == (Doc{}) {
+			delete(docs, path)
+		} else {
+			docs[path] = d
+		}
+	}
+	walk = func(v Value) {
+		switch tv := v.(type) {
+		case *Object:
+			keyPath = append(keyPath, nil)
+			for _, fl := range tv.Fields {
+				keyPath[len(keyPath)-1] = ByName(fl.Key.Name)
+				add(fl.Doc, fl.Comment)
+				walk(fl.Value)
+			}
+			keyPath = keyPath[:len(keyPath)-1]
+		case *Array:
+			keyPath = append(keyPath, nil)
+			for i, e := range tv.Elements {
+				keyPath[len(keyPath)-1] = ByIdx(i)
+				add(e.Doc, e.Comment)
+				walk(e.Value)
+			}
+			keyPath = keyPath[:len(keyPath)-1]
+		}
+	}
+	walk(f.Root)
+	return docs
+}
+
+// splitKeyPath splits a key path without its leading / into its steps,
+// undoing the escaping of / within keys.
+func splitKeyPath(path string) []string {
+	var steps []string
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path) && path[i+1] == '/':
+			b.WriteByte('/')
+			i++
+		case path[i] == '/':
+			steps = append(steps, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(path[i])
+		}
+	}
+	return append(steps, b.String())
+}
+
+// commentText returns the text of a comment group without comment
+// delimiters, leading * on the lines of block comments, trailing space
+// or surrounding blank lines. g may be nil.
+func commentText(g *CommentGroup) string {
+	if g == nil {
+		return ""
+	}
+	var lines []string
+	for _, c := range g.List {
+		lines = append(lines, commentLines(c.Text)...)
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// commentLines returns the lines of text in a single comment.
+func commentLines(text []byte) []string {
+	if isLineComment(text) {
+		text = bytes.TrimPrefix(text, commentStart)
+		text = bytes.TrimPrefix(text, hashComment)
+		text = bytes.TrimPrefix(text, []byte(" "))
+		return []string{string(bytes.TrimRight(text, " \t\r"))}
+	}
+
+	text = bytes.Trim(text[2:len(text)-2], "*") // /* and */, or /** and **/
+	lines := strings.Split(string(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	lines[0] = strings.TrimLeft(lines[0], " \t")
+
+	// Continuation lines are either all decorated with a leading *, or
+	// indented together.
+	rest := lines[1:]
+	starred := true
+	indent := -1
+	for _, line := range rest {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if !strings.HasPrefix(trimmed, "*") {
+			starred = false
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, line := range rest {
+		trimmed := strings.TrimLeft(line, " \t")
+		switch {
+		case trimmed == "":
+			rest[i] = ""
+		case starred:
+			trimmed = strings.TrimPrefix(trimmed, "*")
+			rest[i] = strings.TrimPrefix(trimmed, " ")
+		default:
+			rest[i] = line[indent:]
+		}
+	}
+	return lines
+}
@@ -0,0 +1,89 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+const docSample = `// Server configuration.
+//
+// Reloaded on SIGHUP.
+{
+  // Address to listen on.
+  "listen": ":8080", // host:port
+  /*
+   * Backends, in order of preference.
+   *
+   *   Each is tried in turn.
+   */
+  "backends": [
+    "a.example.com", // primary
+    /* Used only when the primary
+       is down. */
+    "b.example.com",
+  ],
+  "a/b": {
+    /** Seconds. */
+    "timeout": 30,
+    "retries": 3,
+  },
+}
+`
+
+func TestAllDocs(t *testing.T) {
+	f, err := Parse([]byte(docSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]Doc{
+		"/":              {Doc: "Server configuration.\n\nReloaded on SIGHUP."},
+		"/listen":        {"Address to listen on.", "host:port"},
+		"/backends":      {Doc: "Backends, in order of preference.\n\n  Each is tried in turn."},
+		"/backends/0":    {Comment: "primary"},
+		"/backends/1":    {Doc: "Used only when the primary\nis down."},
+		"/a\\/b/timeout": {Doc: "Seconds."},
+	}
+	if docs := AllDocs(f); !reflect.DeepEqual(docs, expected) {
+		t.Errorf("expected %#v, got %#v", expected, docs)
+	}
+
+	for _, tc := range []struct {
+		path, expected string
+	}{
+		{"/", "Server configuration.\n\nReloaded on SIGHUP."},
+		{"/listen", "Address to listen on.\nhost:port"},
+		{"/backends/0", "primary"},
+		{"/backends/1", "Used only when the primary\nis down."},
+		{"/a\\/b/timeout", "Seconds."},
+		{"/a\\/b/retries", ""},
+		{"/a\\/b", ""},
+		{"/missing", ""},
+		{"/backends/2", ""},
+		{"/backends/x", ""},
+		{"/listen/x", ""},
+		{"listen", ""},
+	} {
+		if doc := DocFor(f, tc.path); doc != tc.expected {
+			t.Errorf("path %s: expected %q, got %q", tc.path, tc.expected, doc)
+		}
+	}
+}
+
+func TestDocHashComments(t *testing.T) {
+	f, err := Parse([]byte("{\n  # Address.\n  \"listen\": \":8080\", #host:port\n}"), OptionDialect(DialectHashComments))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc := DocFor(f, "/listen"); doc != "Address.\nhost:port" {
+		t.Errorf("unexpected doc %q", doc)
+	}
+
+	f, err = Parse([]byte("{\"a\": 1, # one\n\"a\": 2}"), OptionDialect(DialectHashComments))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last of duplicate keys wins.
+	if docs := AllDocs(f); len(docs) != 0 {
+		t.Errorf("expected no docs, got %#v", docs)
+	}
+}
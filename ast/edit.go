@@ -0,0 +1,167 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Constructors for new nodes. Nodes built this way have no position.
+
+// NewString returns a string literal for s, quoted and escaped as JSON.
+func NewString(s string) *Literal {
+	return &Literal{Type: LiteralString, Value: quote(s)}
+}
+
+// NewNumber returns a number literal for f using the shortest
+// representation that round trips, as encoding/json does. It panics if
+// f is NaN or infinite since JSON cannot represent them.
+func NewNumber(f float64) *Literal {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		panic(fmt.Sprintf("ast.NewNumber: unsupported value %v", f))
+	}
+	return &Literal{Type: LiteralNumber, Value: formatFloat(f, 64)}
+}
+
+// NewInt returns a number literal for i.
+func NewInt(i int64) *Literal {
+	return &Literal{Type: LiteralNumber, Value: strconv.AppendInt(nil, i, 10)}
+}
+
+func NewBool(b bool) *Literal {
+	if b {
+		return &Literal{Type: LiteralTrue, Value: []byte("true")}
+	}
+	return &Literal{Type: LiteralFalse, Value: []byte("false")}
+}
+
+func NewNull() *Literal {
+	return &Literal{Type: LiteralNull, Value: []byte("null")}
+}
+
+// NewKey returns a field key for name, quoted and escaped as JSON.
+func NewKey(name string) *Key {
+	return &Key{Raw: quote(name), Name: name}
+}
+
+// formatFloat formats f like encoding/json: decimal notation for
+// moderately sized values and exponent notation otherwise.
+func formatFloat(f float64, bits int) []byte {
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b := strconv.AppendFloat(nil, f, format, -1, bits)
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+// Get returns the field with the given decoded name. If the name
+// appears more than once the last field wins, as it does when decoding.
+func (o *Object) Get(name string) *Field {
+	if i := o.index(name); i >= 0 {
+		return o.Fields[i]
+	}
+	return nil
+}
+
+func (o *Object) index(name string) int {
+	for i := len(o.Fields) - 1; i >= 0; i-- {
+		if o.Fields[i].Key.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Set replaces the value of the named field, keeping its comments, or
+// appends a new field if there is none. It returns the field.
+func (o *Object) Set(name string, v Value) *Field {
+	if f := o.Get(name); f != nil {
+		f.Value = v
+		return f
+	}
+	f := &Field{Key: NewKey(name), Value: v}
+	o.Fields = append(o.Fields, f)
+	return f
+}
+
+// Delete removes the named field along with its own comments and
+// reports whether it was present.
+func (o *Object) Delete(name string) bool {
+	i := o.index(name)
+	if i < 0 {
+		return false
+	}
+	o.Fields = append(o.Fields[:i:i], o.Fields[i+1:]...)
+	return true
+}
+
+// InsertBefore inserts a new field immediately before the field named
+// before, or appends it if there is no such field. It returns the new
+// field.
+func (o *Object) InsertBefore(before, name string, v Value) *Field {
+	f := &Field{Key: NewKey(name), Value: v}
+	i := o.index(before)
+	if i < 0 {
+		o.Fields = append(o.Fields, f)
+		return f
+	}
+	fields := make([]*Field, 0, len(o.Fields)+1)
+	fields = append(fields, o.Fields[:i]...)
+	fields = append(fields, f)
+	o.Fields = append(fields, o.Fields[i:]...)
+	return f
+}
+
+// Rename changes the key of the named field in place, keeping its
+// value and comments, and reports whether it was present.
+func (o *Object) Rename(oldName, newName string) bool {
+	f := o.Get(oldName)
+	if f == nil {
+		return false
+	}
+	k := NewKey(newName)
+	k.KeyPos = f.Key.KeyPos
+	f.Key = k
+	return true
+}
+
+// Append adds a value to the end of the array and returns its element.
+func (a *Array) Append(v Value) *Element {
+	e := &Element{Value: v}
+	a.Elements = append(a.Elements, e)
+	return e
+}
+
+// Insert adds a value at index i, shifting later elements up, and
+// returns its element. It panics if i is out of range.
+func (a *Array) Insert(i int, v Value) *Element {
+	if i < 0 || i > len(a.Elements) {
+		panic(fmt.Sprintf("ast.Array.Insert: index %d out of range [0:%d]", i, len(a.Elements)))
+	}
+	e := &Element{Value: v}
+	elements := make([]*Element, 0, len(a.Elements)+1)
+	elements = append(elements, a.Elements[:i]...)
+	elements = append(elements, e)
+	a.Elements = append(elements, a.Elements[i:]...)
+	return e
+}
+
+// Remove deletes the element at index i along with its own comments.
+// It panics if i is out of range.
+func (a *Array) Remove(i int) {
+	if i < 0 || i >= len(a.Elements) {
+		panic(fmt.Sprintf("ast.Array.Remove: index %d out of range [0:%d]", i, len(a.Elements)))
+	}
+	a.Elements = append(a.Elements[:i:i], a.Elements[i+1:]...)
+}
@@ -0,0 +1,118 @@
+package ast
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEditObject(t *testing.T) {
+	root, err := ParseString(`{
+  // Doc for a.
+  "a": 1, // Trailer for a.
+  "b": 2,
+  // Doc for c.
+  "c": 3,
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := root.Root.(*Object)
+
+	if f := obj.Get("b"); f == nil || string(f.Value.(*Literal).Value) != "2" {
+		t.Fatalf("expected b = 2, got %#v", f)
+	}
+	if obj.Get("missing") != nil {
+		t.Fatal("expected no field for missing key")
+	}
+
+	obj.Set("a", NewString("one \"1\""))
+	obj.Set("d", NewBool(true))
+	if !obj.Delete("b") || obj.Delete("b") {
+		t.Fatal("expected b to be deleted exactly once")
+	}
+	obj.InsertBefore("c", "b/2", NewNull())
+	if !obj.Rename("c", "see") || obj.Rename("c", "sea") {
+		t.Fatal("expected c to be renamed exactly once")
+	}
+
+	expected := `{
+  // Doc for a.
+  "a": "one \"1\"", // Trailer for a.
+  "b/2": null,
+  // Doc for c.
+  "see": 3,
+  "d": true,
+}
+`
+	if out := string(FmtJsonr(root)); out != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestEditArray(t *testing.T) {
+	root, err := ParseString(`[
+  1, // one
+  // Doc for two.
+  2,
+]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr := root.Root.(*Array)
+	arr.Append(NewNumber(3.5))
+	arr.Insert(0, NewInt(0))
+	arr.Remove(1)
+	arr.Insert(len(arr.Elements), NewNumber(1e21))
+
+	expected := `[
+  0,
+  // Doc for two.
+  2,
+  3.5,
+  1e+21,
+]
+`
+	if out := string(FmtJsonr(root)); out != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	for _, i := range []int{-1, len(arr.Elements)} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected Remove(%d) to panic", i)
+				}
+			}()
+			arr.Remove(i)
+		}()
+	}
+}
+
+func TestNewLiterals(t *testing.T) {
+	checkLiteral := func(l *Literal, typ LiteralType, expected string) {
+		if l.Type != typ || string(l.Value) != expected {
+			t.Errorf("expected %v %s, got %v %s", typ, expected, l.Type, l.Value)
+		}
+	}
+	checkLiteral(NewString("tab\there"), LiteralString, `"tab\there"`)
+	checkLiteral(NewNumber(1), LiteralNumber, "1")
+	checkLiteral(NewNumber(-0.000001), LiteralNumber, "-0.000001")
+	checkLiteral(NewNumber(1e-7), LiteralNumber, "1e-7")
+	checkLiteral(NewInt(math.MinInt64), LiteralNumber, "-9223372036854775808")
+	checkLiteral(NewBool(false), LiteralFalse, "false")
+	checkLiteral(NewNull(), LiteralNull, "null")
+
+	// Constructors must not share state.
+	n := NewNull()
+	n.Value[0] = 'N'
+	checkLiteral(NewNull(), LiteralNull, "null")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected NewNumber(NaN) to panic")
+		}
+	}()
+	NewNumber(math.NaN())
+}
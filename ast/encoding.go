@@ -0,0 +1,173 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is a Unicode encoding of JSONR source. JSONR is UTF-8, but
+// files saved by some Windows tools are UTF-16. Parse, Strip and
+// Unmarshal transcode UTF-16 and UTF-32 input to UTF-8 before reading
+// it, except in strict mode since RFC 8259 requires UTF-8, and drop any
+// byte order mark. Offsets and positions in errors and the AST refer to
+// the result, as returned by DecodeSource. Streams must be UTF-8.
+type Encoding int
+
+const (
+	EncodingUTF8 Encoding = iota
+	EncodingUTF16LE
+	EncodingUTF16BE
+	EncodingUTF32LE
+	EncodingUTF32BE
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+	utf32LEBOM = []byte{0xFF, 0xFE, 0, 0}
+	utf32BEBOM = []byte{0, 0, 0xFE, 0xFF}
+)
+
+// DetectEncoding returns the encoding of source from its byte order
+// mark, if it has one, or else from the pattern of zero bytes that an
+// ASCII character at its start produces, as RFC 4627 suggests.
+func DetectEncoding(src []byte) (enc Encoding, bom bool) {
+	switch {
+	case bytes.HasPrefix(src, utf8BOM):
+		return EncodingUTF8, true
+	case bytes.HasPrefix(src, utf32LEBOM):
+		return EncodingUTF32LE, true
+	case bytes.HasPrefix(src, utf32BEBOM):
+		return EncodingUTF32BE, true
+	case bytes.HasPrefix(src, utf16LEBOM):
+		return EncodingUTF16LE, true
+	case bytes.HasPrefix(src, utf16BEBOM):
+		return EncodingUTF16BE, true
+	}
+	switch {
+	case len(src) >= 4 && src[0] == 0 && src[1] == 0 && src[2] == 0 && src[3] != 0:
+		return EncodingUTF32BE, false
+	case len(src) >= 4 && src[0] != 0 && src[1] == 0 && src[2] == 0 && src[3] == 0:
+		return EncodingUTF32LE, false
+	case len(src) >= 2 && src[0] == 0 && src[1] != 0:
+		return EncodingUTF16BE, false
+	case len(src) >= 2 && src[0] != 0 && src[1] == 0:
+		return EncodingUTF16LE, false
+	}
+	return EncodingUTF8, false
+}
+
+// DecodeSource returns source as UTF-8 without a byte order mark.
+// Unpaired surrogates and invalid code points are replaced with
+// utf8.RuneError.
+func DecodeSource(src []byte) ([]byte, error) {
+	enc, bom := DetectEncoding(src)
+	if enc == EncodingUTF8 {
+		if bom {
+			src = src[len(utf8BOM):]
+		}
+		return src, nil
+	}
+	return transcode(src, enc, bom)
+}
+
+// EncodeSource encodes UTF-8 source as enc, with a byte order mark if
+// bom is set. It undoes DecodeSource.
+func EncodeSource(src []byte, enc Encoding, bom bool) []byte {
+	var b []byte
+	if bom {
+		switch enc {
+		case EncodingUTF8:
+			b = append(b, utf8BOM...)
+		case EncodingUTF16LE:
+			b = append(b, utf16LEBOM...)
+		case EncodingUTF16BE:
+			b = append(b, utf16BEBOM...)
+		case EncodingUTF32LE:
+			b = append(b, utf32LEBOM...)
+		case EncodingUTF32BE:
+			b = append(b, utf32BEBOM...)
+		}
+	}
+	if enc == EncodingUTF8 {
+		return append(b, src...)
+	}
+
+	order := byteOrder(enc)
+	var unit [4]byte
+	for _, r := range string(src) {
+		switch enc {
+		case EncodingUTF16LE, EncodingUTF16BE:
+			if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+				order.PutUint16(unit[:], uint16(r1))
+				b = append(b, unit[:2]...)
+				r = r2
+			}
+			order.PutUint16(unit[:], uint16(r))
+			b = append(b, unit[:2]...)
+		default:
+			order.PutUint32(unit[:], uint32(r))
+			b = append(b, unit[:]...)
+		}
+	}
+	return b
+}
+
+func byteOrder(enc Encoding) binary.ByteOrder {
+	if enc == EncodingUTF16LE || enc == EncodingUTF32LE {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// transcode converts UTF-16 or UTF-32 source to UTF-8.
+func transcode(src []byte, enc Encoding, bom bool) ([]byte, error) {
+	order := byteOrder(enc)
+	size := 2
+	if enc == EncodingUTF32LE || enc == EncodingUTF32BE {
+		size = 4
+	}
+	start := 0
+	if bom {
+		start = size
+	}
+	if (len(src)-start)%size != 0 {
+		return nil, &SyntaxError{Msg: "truncated UTF-16 or UTF-32 input", Offset: len(src)}
+	}
+
+	b := make([]byte, 0, len(src))
+	if size == 4 {
+		for i := start; i < len(src); i += 4 {
+			b = appendRune(b, rune(order.Uint32(src[i:])))
+		}
+		return b, nil
+	}
+	units := make([]uint16, 0, (len(src)-start)/2)
+	for i := start; i < len(src); i += 2 {
+		units = append(units, order.Uint16(src[i:]))
+	}
+	for _, r := range utf16.Decode(units) {
+		b = appendRune(b, r)
+	}
+	return b, nil
+}
+
+func appendRune(b []byte, r rune) []byte {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r) // invalid runes encode as RuneError
+	return append(b, buf[:n]...)
+}
+
+// sourceUTF8 decodes the input of Parse, Strip or Unmarshal as
+// DecodeSource does. It is called once by each, before scanning.
+// Strict input must already be UTF-8, though it may have a byte order
+// mark.
+func sourceUTF8(in []byte, strict bool) ([]byte, error) {
+	if strict {
+		return bytes.TrimPrefix(in, utf8BOM), nil
+	}
+	return DecodeSource(in)
+}
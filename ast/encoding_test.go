@@ -0,0 +1,140 @@
+package ast
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		src string
+		enc Encoding
+		bom bool
+	}{
+		{"", EncodingUTF8, false},
+		{"{}", EncodingUTF8, false},
+		{"\xEF\xBB\xBF{}", EncodingUTF8, true},
+		{"\xFF\xFE{\x00}\x00", EncodingUTF16LE, true},
+		{"\xFE\xFF\x00{\x00}", EncodingUTF16BE, true},
+		{"\xFF\xFE\x00\x00{\x00\x00\x00", EncodingUTF32LE, true},
+		{"\x00\x00\xFE\xFF\x00\x00\x00{", EncodingUTF32BE, true},
+		{"{\x00}\x00", EncodingUTF16LE, false},
+		{"\x00{\x00}", EncodingUTF16BE, false},
+		{"{\x00\x00\x00", EncodingUTF32LE, false},
+		{"\x00\x00\x00{", EncodingUTF32BE, false},
+	}
+	for _, tc := range tests {
+		enc, bom := DetectEncoding([]byte(tc.src))
+		if enc != tc.enc || bom != tc.bom {
+			t.Errorf("%q: expected %v %v, got %v %v", tc.src, tc.enc, tc.bom, enc, bom)
+		}
+	}
+}
+
+func TestEncodeSource(t *testing.T) {
+	src := []byte("{\"k\": \"é 😀\"} // ü\n")
+	encodings := []Encoding{EncodingUTF8, EncodingUTF16LE, EncodingUTF16BE, EncodingUTF32LE, EncodingUTF32BE}
+	for _, enc := range encodings {
+		for _, bom := range []bool{false, true} {
+			encoded := EncodeSource(src, enc, bom)
+			if e, b := DetectEncoding(encoded); e != enc || b != bom {
+				t.Errorf("%v %v: detected as %v %v", enc, bom, e, b)
+			}
+			decoded, err := DecodeSource(encoded)
+			if err != nil {
+				t.Errorf("%v %v: %v", enc, bom, err)
+			} else if !bytes.Equal(decoded, src) {
+				t.Errorf("%v %v: expected %q, got %q", enc, bom, src, decoded)
+			}
+		}
+	}
+
+	if _, err := DecodeSource([]byte("{\x00}")); err == nil {
+		t.Error("expected an error for truncated UTF-16")
+	}
+}
+
+func TestParseEncodings(t *testing.T) {
+	src := []byte("// comment\n{\"k\": \"é\", \"n\": [1, 2]}\n")
+	sources := [][]byte{
+		append([]byte("\xEF\xBB\xBF"), src...),
+		EncodeSource(src, EncodingUTF16LE, true),
+		EncodeSource(src, EncodingUTF16BE, false),
+		EncodeSource(src, EncodingUTF32LE, false),
+	}
+	for _, in := range sources {
+		root, err := Parse(in)
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+			continue
+		}
+		if out := string(FmtJson(root, OptionLineWidth(0))); out != "{\n  \"k\": \"é\",\n  \"n\": [\n    1,\n    2\n  ]\n}\n" {
+			t.Errorf("%q: unexpected output:\n%s", in, out)
+		}
+
+		stripped, err := Strip(in)
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+		} else if !bytes.Contains(stripped, []byte(`"é"`)) {
+			t.Errorf("%q: unexpected stripped output %q", in, stripped)
+		}
+
+		var v struct {
+			K string
+			N []int
+		}
+		if err := Unmarshal(in, &v); err != nil {
+			t.Errorf("%q: %v", in, err)
+		} else if v.K != "é" || len(v.N) != 2 {
+			t.Errorf("%q: unexpected value %+v", in, v)
+		}
+	}
+
+	// RFC 8259 requires UTF-8, though it allows a byte order mark.
+	if _, err := Parse(append([]byte("\xEF\xBB\xBF"), src...), OptionStrict); err != nil {
+		t.Errorf("expected strict mode to accept a byte order mark: %v", err)
+	}
+	if _, err := Parse(EncodeSource(src, EncodingUTF16LE, true), OptionStrict); err == nil {
+		t.Error("expected strict mode to reject UTF-16")
+	}
+	if err := UnmarshalStrict(EncodeSource(src, EncodingUTF16LE, true), new(interface{})); err == nil {
+		t.Error("expected UnmarshalStrict to reject UTF-16")
+	}
+}
+
+func TestEncodingErrorPositions(t *testing.T) {
+	// Offsets and positions both refer to the decoded source, after the
+	// byte order mark.
+	for _, in := range [][]byte{
+		[]byte("\xEF\xBB\xBF[1 2]"),
+		EncodeSource([]byte("[1 2]"), EncodingUTF16LE, true),
+	} {
+		var serr *SyntaxError
+		if _, err := Parse(in); !errors.As(err, &serr) || serr.Offset != 3 {
+			t.Errorf("%q: expected a syntax error at offset 3, got %v", in, err)
+		}
+		if err := Unmarshal(in, new(interface{})); !errors.As(err, &serr) || serr.Offset != 3 {
+			t.Errorf("%q: expected a syntax error at offset 3, got %v", in, err)
+		}
+		_, err := ParseFile(NewFileSet(), "x.json", in, OptionRecover)
+		if el, ok := err.(ErrorList); !ok || len(el) != 1 || el[0].Pos.Offset != 3 || el[0].Pos.Column != 4 {
+			t.Errorf("%q: expected an error at 1:4, got %v", in, err)
+		}
+	}
+
+	// A stream drops its byte order mark the same way, however it is
+	// read.
+	in := "\xEF\xBB\xBF1\n[2 3]\n"
+	d := NewStreamDecoder(iotest.OneByteReader(strings.NewReader(in)))
+	var v interface{}
+	if err := d.Decode(&v); err != nil || v != 1.0 {
+		t.Fatalf("expected 1, got %v, %v", v, err)
+	}
+	var serr *SyntaxError
+	if err := d.Decode(&v); !errors.As(err, &serr) || serr.Offset != 5 {
+		t.Errorf("expected a syntax error at offset 5, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package ast
+
+import (
+	"bytes"
+	"math/big"
+)
+
+// EqualOption makes Equal stricter or looser than comparing values the
+// way a decoder would see them.
+type EqualOption func(*equality)
+
+var plusSign = []byte("+")
+
+type equality struct {
+	comments bool
+	keyOrder bool
+	numeric  bool
+}
+
+// Compare the comments attached to nodes, byte for byte.
+func OptionCompareComments(e *equality) {
+	e.comments = true
+}
+
+// Compare the order of fields in objects, and every field rather than
+// only the last of those with the same key.
+func OptionCompareKeyOrder(e *equality) {
+	e.keyOrder = true
+}
+
+// Compare numbers by value, so that 1, 1.0 and 10e-1 are equal.
+func OptionNumericEquivalence(e *equality) {
+	e.numeric = true
+}
+
+// Equal reports whether two nodes are equivalent. By default comments,
+// positions and layout are ignored, strings are compared after decoding
+// escapes and objects are compared as a decoder would see them: in any
+// order, and with only the last of fields with the same key. Numbers
+// are compared as written, after any Dialect syntax is rewritten as
+// JSON, since 1 and 1.0 do not decode into the same Go types.
+func Equal(a, b Node, options ...EqualOption) bool {
+	e := &equality{}
+	for _, opt := range options {
+		opt(e)
+	}
+	return e.equal(a, b)
+}
+
+func (e *equality) equal(a, b Node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	switch x := a.(type) {
+	case *File:
+		y, ok := b.(*File)
+		return ok && e.commentsEqual(x.Doc, y.Doc) && e.commentsEqual(x.Comment, y.Comment) &&
+			e.equal(x.Root, y.Root)
+	case *Literal:
+		y, ok := b.(*Literal)
+		return ok && e.literalEqual(x, y)
+	case *Object:
+		y, ok := b.(*Object)
+		return ok && e.objectEqual(x, y)
+	case *Array:
+		y, ok := b.(*Array)
+		if !ok || len(x.Elements) != len(y.Elements) || !e.commentsEqual(x.Comment, y.Comment) {
+			return false
+		}
+		for i := range x.Elements {
+			if !e.equal(x.Elements[i], y.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *BadValue:
+		y, ok := b.(*BadValue)
+		return ok && bytes.Equal(x.Text, y.Text)
+	case *Field:
+		y, ok := b.(*Field)
+		return ok && e.commentsEqual(x.Doc, y.Doc) && e.commentsEqual(x.Comment, y.Comment) &&
+			x.Key.Name == y.Key.Name && e.equal(x.Value, y.Value)
+	case *Key:
+		y, ok := b.(*Key)
+		return ok && x.Name == y.Name
+	case *Element:
+		y, ok := b.(*Element)
+		return ok && e.commentsEqual(x.Doc, y.Doc) && e.commentsEqual(x.Comment, y.Comment) &&
+			e.equal(x.Value, y.Value)
+	case *Comment:
+		y, ok := b.(*Comment)
+		return ok && bytes.Equal(x.Text, y.Text)
+	case *CommentGroup:
+		y, ok := b.(*CommentGroup)
+		return ok && commentGroupsEqual(x, y)
+	}
+	return false
+}
+
+// commentsEqual compares the comments attached to a node, which are
+// always equal unless comparing comments. Either may be nil.
+func (e *equality) commentsEqual(a, b *CommentGroup) bool {
+	return !e.comments || commentGroupsEqual(a, b)
+}
+
+func commentGroupsEqual(a, b *CommentGroup) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if len(a.List) != len(b.List) {
+		return false
+	}
+	for i := range a.List {
+		if !bytes.Equal(a.List[i].Text, b.List[i].Text) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *equality) literalEqual(a, b *Literal) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	switch a.Type {
+	case LiteralString:
+		x, okx := unquoteBytes(a.Value)
+		y, oky := unquoteBytes(b.Value)
+		return okx && oky && bytes.Equal(x, y)
+	case LiteralNumber:
+		x, y := jsonNumber(a.Value), jsonNumber(b.Value)
+		if x[0] == 'n' || y[0] == 'n' {
+			// Infinity and NaN are null in JSON, whatever their sign.
+			return bytes.Equal(bytes.TrimPrefix(a.Value, plusSign), bytes.TrimPrefix(b.Value, plusSign))
+		}
+		if bytes.Equal(x, y) {
+			return true
+		}
+		if !e.numeric {
+			return false
+		}
+		fx, _, errx := big.ParseFloat(string(x), 10, 256, big.ToNearestEven)
+		fy, _, erry := big.ParseFloat(string(y), 10, 256, big.ToNearestEven)
+		return errx == nil && erry == nil && fx.Cmp(fy) == 0
+	}
+	return true
+}
+
+func (e *equality) objectEqual(a, b *Object) bool {
+	if !e.commentsEqual(a.Doc, b.Doc) || !e.commentsEqual(a.Comment, b.Comment) {
+		return false
+	}
+	if e.keyOrder {
+		if len(a.Fields) != len(b.Fields) {
+			return false
+		}
+		for i := range a.Fields {
+			if !e.equal(a.Fields[i], b.Fields[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	x, y := lastFields(a), lastFields(b)
+	if len(x) != len(y) {
+		return false
+	}
+	for name, fx := range x {
+		fy, ok := y[name]
+		if !ok || !e.equal(fx, fy) {
+			return false
+		}
+	}
+	return true
+}
+
+// lastFields returns the fields of an object by name, with only the
+// last of those with the same name.
+func lastFields(o *Object) map[string]*Field {
+	m := make(map[string]*Field, len(o.Fields))
+	for _, f := range o.Fields {
+		m[f.Key.Name] = f
+	}
+	return m
+}
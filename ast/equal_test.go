@@ -0,0 +1,99 @@
+package ast
+
+import (
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	for _, tc := range []struct {
+		a, b    string
+		options []EqualOption
+		equal   bool
+	}{
+		{`{"a": 1, "b": [true, null]}`, `/* c */ {"b": [true, null,], "a": 1} // c`, nil, true},
+		{`{"a": 1, "b": 2}`, `{"b": 2, "a": 1}`, []EqualOption{OptionCompareKeyOrder}, false},
+		{`{"a": 1, "b": 2}`, "{\n  \"a\": 1,\n  \"b\": 2,\n}", []EqualOption{OptionCompareKeyOrder}, true},
+		{`{"a": 1, "a": 2}`, `{"a": 2}`, nil, true},
+		{`{"a": 1, "a": 2}`, `{"a": 2}`, []EqualOption{OptionCompareKeyOrder}, false},
+		{`{"a": 1}`, `{"a": 1, "b": 1}`, nil, false},
+		{`{"a": 1}`, `{"b": 1}`, nil, false},
+		{`{"a": "é\n"}`, `{"a": "é\n"}`, nil, true},
+		{"\"\"\"\n  x\n  \"\"\"", `"x\n"`, nil, true},
+		{`"a"`, `"b"`, nil, false},
+		{`1`, `1.0`, nil, false},
+		{`1`, `1.0`, []EqualOption{OptionNumericEquivalence}, true},
+		{`[1e2, -0.5]`, `[100, -5E-1]`, []EqualOption{OptionNumericEquivalence}, true},
+		{`12345678901234567890`, `12345678901234567891`, []EqualOption{OptionNumericEquivalence}, false},
+		{`1`, `"1"`, nil, false},
+		{`[1]`, `[1, 2]`, nil, false},
+		{`[1, 2]`, `[2, 1]`, nil, false},
+		{`{}`, `[]`, nil, false},
+		{`null`, `null`, nil, true},
+		{`// a
+{
+  // b
+  "x": 1, // c
+}`, `// a
+{"x": 1, // c
+}`, []EqualOption{OptionCompareComments}, false},
+		{"{\n  \"x\": 1,\n  // c\n  \"y\": 2,\n}", "{\n  \"y\": 2,\n  \"x\": 1, // c\n}", []EqualOption{OptionCompareComments}, false},
+		{"{\n  \"x\": 1,\n  // c\n  \"y\": 2,\n}", "{\n  // c\n  \"y\": 2,\n  \"x\": 1,\n}", []EqualOption{OptionCompareComments}, true},
+	} {
+		a, err := Parse([]byte(tc.a))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.a, err)
+		}
+		b, err := Parse([]byte(tc.b))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.b, err)
+		}
+		if eq := Equal(a, b, tc.options...); eq != tc.equal {
+			t.Errorf("%s and %s: expected %v, got %v", tc.a, tc.b, tc.equal, eq)
+		}
+		if eq := Equal(b, a, tc.options...); eq != tc.equal {
+			t.Errorf("%s and %s: expected %v, got %v", tc.b, tc.a, tc.equal, eq)
+		}
+	}
+}
+
+func TestEqualDialect(t *testing.T) {
+	for _, tc := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{`{a: 'x', b: 0x10, c: .5}`, `{"a": "x", "b": 16, "c": 0.5}`, true},
+		{`Infinity`, `+Infinity`, true},
+		{`Infinity`, `-Infinity`, false},
+		{`NaN`, `null`, false},
+	} {
+		a, err := Parse([]byte(tc.a), OptionDialect(DialectJSON5))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.a, err)
+		}
+		b, err := Parse([]byte(tc.b), OptionDialect(DialectJSON5))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.b, err)
+		}
+		if eq := Equal(a, b, OptionNumericEquivalence); eq != tc.equal {
+			t.Errorf("%s and %s: expected %v, got %v", tc.a, tc.b, tc.equal, eq)
+		}
+	}
+}
+
+func TestEqualNodes(t *testing.T) {
+	if !Equal(nil, nil) {
+		t.Error("expected nil to equal nil")
+	}
+	if Equal(NewNull(), nil) || Equal(nil, NewNull()) {
+		t.Error("expected nil to differ from null")
+	}
+	if !Equal(NewString("x"), NewString("x")) || Equal(NewKey("x"), NewString("x")) {
+		t.Error("expected node types to be compared")
+	}
+
+	f, _ := Parse([]byte(`[1, ?]`), OptionRecover)
+	g, _ := Parse([]byte(`[1, ?]`), OptionRecover)
+	if !Equal(f, g) {
+		t.Error("expected equal bad values")
+	}
+}
@@ -0,0 +1,45 @@
+package ast
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Error is a problem found while parsing with OptionRecover.
+type Error struct {
+	Pos Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": " + e.Msg
+	}
+	return e.Msg
+}
+
+// ErrorList is every problem found while parsing with OptionRecover,
+// in source order.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+func (l ErrorList) sort() {
+	sort.SliceStable(l, func(i, j int) bool { return l[i].Pos.Offset < l[j].Pos.Offset })
+}
+
+// Keep parsing after errors. Parse returns a best-effort tree in which
+// malformed values are replaced by *BadValue nodes, along with an
+// ErrorList of every problem found. Recovery resumes at the next comma
+// or closing bracket, so one typo does not hide the rest of the file.
+func OptionRecover(p *astParser) {
+	p.recover = true
+}
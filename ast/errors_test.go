@@ -0,0 +1,94 @@
+package ast
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRecover(t *testing.T) {
+	in := `{
+  "a": tru,
+  "b" 2,
+  "c": [1 2, }],
+  d: 4,
+  "e": 5
+}`
+	fset := NewFileSet()
+	f, err := ParseFile(fset, "test.jsonr", []byte(in), OptionRecover)
+	var el ErrorList
+	if !errors.As(err, &el) {
+		t.Fatalf("expected ErrorList, got %v", err)
+	}
+	expected := []string{
+		"test.jsonr:2:8: failed parsing true",
+		"test.jsonr:3:7: expected colon delimiter for key token",
+		"test.jsonr:4:11: missing comma in array",
+		`test.jsonr:4:14: unexpected "}", expected value`,
+		`test.jsonr:5:3: invalid element: "d"`,
+	}
+	if len(el) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(el), el)
+	}
+	for i, e := range el {
+		if e.Error() != expected[i] {
+			t.Errorf("error %d: expected %q, got %q", i, expected[i], e.Error())
+		}
+	}
+
+	obj := f.Root.(*Object)
+	if len(obj.Fields) != 4 {
+		t.Fatalf("expected 4 fields, got %d", len(obj.Fields))
+	}
+	bad, ok := obj.Fields[0].Value.(*BadValue)
+	if !ok || string(bad.Text) != "tru" {
+		t.Errorf("expected bad value tru, got %#v", obj.Fields[0].Value)
+	} else if p := fset.Position(bad.Pos()); p.String() != "test.jsonr:2:8" {
+		t.Errorf("expected bad value at test.jsonr:2:8, got %s", p)
+	}
+	if lit, ok := obj.Fields[1].Value.(*Literal); !ok || string(lit.Value) != "2" {
+		t.Errorf("expected field b to keep its value, got %#v", obj.Fields[1].Value)
+	}
+	if arr, ok := obj.Fields[2].Value.(*Array); !ok || len(arr.Elements) != 3 {
+		t.Errorf("expected array of 3 elements, got %#v", obj.Fields[2].Value)
+	}
+	if obj.Fields[3].Key.Name != "e" {
+		t.Errorf("expected field e last, got %s", obj.Fields[3].Key.Name)
+	}
+}
+
+func TestParseRecoverErrors(t *testing.T) {
+	for in, expected := range map[string]string{
+		`[1, , 2]`:    `1:5: unexpected ",", expected value`,
+		`{"a": [1, 2`: "1:12: unexpected EOF reading array",
+		`{,}`:         `1:2: invalid key token ","`,
+		`{"a" }`:      "1:6: expected colon delimiter for key token",
+		`[1] 2`:       `1:5: unexpected "2" after top-level value; use ParseAll for a stream of values`,
+		``:            "1:1: unexpected EOF, expected value",
+		`"abc`:        "1:1: unexpected EOF scanning string",
+		"[1, /* c":    "1:5: unexpected EOF scanning comment (and 1 more errors)",
+	} {
+		f, err := Parse([]byte(in), OptionRecover)
+		if f == nil {
+			t.Errorf("input %q: expected a partial tree", in)
+		}
+		if err == nil || err.Error() != expected {
+			t.Errorf("input %q: expected %s, got %v", in, expected, err)
+		}
+		if _, err := Parse([]byte(in)); err == nil {
+			t.Errorf("input %q: expected error without OptionRecover", in)
+		}
+	}
+
+	f, err := Parse([]byte(`{"a": [1, 2]}`), OptionRecover)
+	if err != nil || f == nil {
+		t.Errorf("expected no errors for valid input, got %v", err)
+	}
+
+	files, err := ParseAll([]byte("{\"a\": 1}\n{\"a\": x}\n{\"a\": 3}\n"), OptionRecover)
+	if err == nil || err.Error() != "2:7: invalid element: \"x\"" {
+		t.Errorf("expected error on line 2, got %v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("expected 3 files, got %d", len(files))
+	}
+}
@@ -0,0 +1,198 @@
+package ast
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// structField describes how a struct field maps to an object field,
+// following the rules of encoding/json for `json` tags and embedded
+// structs.
+type structField struct {
+	name      string
+	index     []int
+	typ       reflect.Type
+	tagged    bool
+	omitEmpty bool
+	quoted    bool // the ",string" option
+}
+
+var fieldCache sync.Map // map[reflect.Type][]structField
+
+// cachedTypeFields returns the encodable fields of a struct type in
+// declaration order.
+func cachedTypeFields(t reflect.Type) []structField {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]structField)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.([]structField)
+}
+
+func typeFields(t reflect.Type) []structField {
+	var fields []structField
+	collectFields(t, nil, map[reflect.Type]bool{}, &fields)
+
+	// Resolve name conflicts: the shallowest field wins, then a tagged
+	// field, otherwise the name is ambiguous and all are dropped.
+	byName := make(map[string][]int)
+	for i, f := range fields {
+		byName[f.name] = append(byName[f.name], i)
+	}
+	keep := make([]bool, len(fields))
+	for _, idxs := range byName {
+		best := -1
+		ambiguous := false
+		for _, i := range idxs {
+			switch {
+			case best < 0:
+				best = i
+			case len(fields[i].index) < len(fields[best].index):
+				best, ambiguous = i, false
+			case len(fields[i].index) > len(fields[best].index):
+			case fields[i].tagged && !fields[best].tagged:
+				best, ambiguous = i, false
+			case fields[i].tagged == fields[best].tagged:
+				ambiguous = true
+			}
+		}
+		if !ambiguous {
+			keep[best] = true
+		}
+	}
+
+	out := fields[:0]
+	for i, f := range fields {
+		if keep[i] {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func collectFields(t reflect.Type, index []int, visited map[reflect.Type]bool, fields *[]structField) {
+	if visited[t] {
+		return
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, tagOptions("")
+		if comma := strings.IndexByte(tag, ','); comma >= 0 {
+			name, opts = tag[:comma], tagOptions(tag[comma+1:])
+		}
+		if !isValidTag(name) {
+			name = ""
+		}
+
+		ft := sf.Type
+		if sf.Anonymous {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if sf.PkgPath != "" && ft.Kind() != reflect.Struct {
+				// Unexported non-struct embedded field.
+				continue
+			}
+			if name == "" && ft.Kind() == reflect.Struct {
+				collectFields(ft, appendIndex(index, i), visited, fields)
+				continue
+			}
+		} else if sf.PkgPath != "" {
+			// Unexported field.
+			continue
+		}
+
+		f := structField{
+			name:      name,
+			index:     appendIndex(index, i),
+			typ:       sf.Type,
+			tagged:    name != "",
+			omitEmpty: opts.contains("omitempty"),
+		}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		if opts.contains("string") {
+			// As in encoding/json, ",string" also applies to an unnamed
+			// pointer to a scalar.
+			ft := sf.Type
+			if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			switch ft.Kind() {
+			case reflect.Bool,
+				reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+				reflect.Float32, reflect.Float64,
+				reflect.String:
+				f.quoted = true
+			}
+		}
+		*fields = append(*fields, f)
+	}
+}
+
+// tagOptions is the comma-separated list of options after the name in
+// a json struct tag.
+type tagOptions string
+
+// contains reports whether the options include name.
+func (o tagOptions) contains(name string) bool {
+	s := string(o)
+	for s != "" {
+		opt := s
+		s = ""
+		if comma := strings.IndexByte(opt, ','); comma >= 0 {
+			opt, s = opt[:comma], opt[comma+1:]
+		}
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+func appendIndex(index []int, i int) []int {
+	return append(index[:len(index):len(index)], i)
+}
+
+func isValidTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// Backslash and quote chars are reserved, but
+			// otherwise any punctuation chars are allowed
+			// in a tag name.
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
+
+// fieldByIndex returns the struct field for index, following embedded
+// pointers. It reports false if a nil embedded pointer is in the way.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
@@ -0,0 +1,317 @@
+package ast
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+type valueConverter struct {
+	sortMapKeys bool
+	seen        map[interface{}]bool // containers being converted, to detect cycles
+}
+
+type ValueOption func(c *valueConverter)
+
+// Sort the keys of Go maps. Without this, keys appear in map iteration
+// order, which is unspecified.
+func OptionSortMapKeys(c *valueConverter) {
+	c.sortMapKeys = true
+}
+
+var (
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// FromValue builds an AST from a Go value following the encoding rules
+// of json.Marshal: struct fields keep their declaration order and
+// honor `json` tags, and types implementing json.Marshaler or
+// encoding.TextMarshaler encode themselves. The result has no comments
+// or positions; add comments through the AST and format it with
+// FmtJsonr.
+func FromValue(v interface{}, options ...ValueOption) (*File, error) {
+	c := &valueConverter{seen: make(map[interface{}]bool)}
+	for _, o := range options {
+		o(c)
+	}
+	root, err := c.convert(reflect.ValueOf(v), false)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Root: root}, nil
+}
+
+func (c *valueConverter) convert(v reflect.Value, quoted bool) (Value, error) {
+	if !v.IsValid() {
+		return NewNull(), nil
+	}
+
+	t := v.Type()
+	if t.Kind() != reflect.Ptr && v.CanAddr() &&
+		(reflect.PtrTo(t).Implements(marshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)) {
+		v = v.Addr()
+		t = v.Type()
+	}
+	if t.Implements(marshalerType) {
+		if (t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface) && v.IsNil() {
+			return NewNull(), nil
+		}
+		b, err := v.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, &json.MarshalerError{Type: t, Err: err}
+		}
+		f, err := Parse(b)
+		if err != nil {
+			return nil, &json.MarshalerError{Type: t, Err: err}
+		}
+		clearPos(f.Root)
+		return f.Root, nil
+	}
+	if t.Implements(textMarshalerType) {
+		if (t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface) && v.IsNil() {
+			return NewNull(), nil
+		}
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, &json.MarshalerError{Type: t, Err: err}
+		}
+		return NewString(string(b)), nil
+	}
+
+	if t == numberType {
+		s := v.String()
+		if s == "" {
+			s = "0"
+		}
+		if !isValidNumber([]byte(s)) {
+			return nil, fmt.Errorf("json: invalid number literal %q", s)
+		}
+		return quoteLiteral(&Literal{Type: LiteralNumber, Value: []byte(s)}, quoted), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return quoteLiteral(NewBool(v.Bool()), quoted), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return quoteLiteral(NewInt(v.Int()), quoted), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		lit := &Literal{Type: LiteralNumber, Value: strconv.AppendUint(nil, v.Uint(), 10)}
+		return quoteLiteral(lit, quoted), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, &json.UnsupportedValueError{Value: v, Str: strconv.FormatFloat(f, 'g', -1, t.Bits())}
+		}
+		lit := &Literal{Type: LiteralNumber, Value: formatFloat(f, t.Bits())}
+		return quoteLiteral(lit, quoted), nil
+	case reflect.String:
+		return quoteLiteral(NewString(v.String()), quoted), nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+		return c.convert(v.Elem(), false)
+	case reflect.Ptr:
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+		if err := c.enter(v); err != nil {
+			return nil, err
+		}
+		defer c.leave(v)
+		return c.convert(v.Elem(), quoted)
+	case reflect.Struct:
+		return c.convertStruct(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+		if err := c.enter(v); err != nil {
+			return nil, err
+		}
+		defer c.leave(v)
+		return c.convertMap(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+		if isByteSlice(t) {
+			return NewString(base64.StdEncoding.EncodeToString(v.Bytes())), nil
+		}
+		if err := c.enter(v); err != nil {
+			return nil, err
+		}
+		defer c.leave(v)
+		return c.convertArray(v)
+	case reflect.Array:
+		return c.convertArray(v)
+	default:
+		return nil, &json.UnsupportedTypeError{Type: t}
+	}
+}
+
+func quoteLiteral(lit *Literal, quoted bool) *Literal {
+	if !quoted {
+		return lit
+	}
+	return NewString(string(lit.Value))
+}
+
+func isByteSlice(t reflect.Type) bool {
+	if t.Elem().Kind() != reflect.Uint8 {
+		return false
+	}
+	pt := reflect.PtrTo(t.Elem())
+	return !pt.Implements(marshalerType) && !pt.Implements(textMarshalerType)
+}
+
+// cycleKey identifies a container for cycle detection. Slices are keyed
+// on their length too, as encoding/json does, so that a slice holding a
+// shorter view of its own backing array is not taken for a cycle.
+func cycleKey(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Slice {
+		return struct {
+			ptr uintptr
+			len int
+		}{v.Pointer(), v.Len()}
+	}
+	return v.Pointer()
+}
+
+func (c *valueConverter) enter(v reflect.Value) error {
+	k := cycleKey(v)
+	if c.seen[k] {
+		return &json.UnsupportedValueError{Value: v, Str: fmt.Sprintf("encountered a cycle via %s", v.Type())}
+	}
+	c.seen[k] = true
+	return nil
+}
+
+func (c *valueConverter) leave(v reflect.Value) {
+	delete(c.seen, cycleKey(v))
+}
+
+func (c *valueConverter) convertStruct(v reflect.Value) (Value, error) {
+	obj := &Object{}
+	for _, sf := range cachedTypeFields(v.Type()) {
+		fv, ok := fieldByIndex(v, sf.index)
+		if !ok || (sf.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		val, err := c.convert(fv, sf.quoted)
+		if err != nil {
+			return nil, err
+		}
+		obj.Fields = append(obj.Fields, &Field{Key: NewKey(sf.name), Value: val})
+	}
+	return obj, nil
+}
+
+func (c *valueConverter) convertMap(v reflect.Value) (Value, error) {
+	type entry struct {
+		name string
+		val  reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		name, err := mapKeyName(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{name, iter.Value()})
+	}
+	if c.sortMapKeys {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	}
+
+	obj := &Object{Fields: make([]*Field, 0, len(entries))}
+	for _, e := range entries {
+		val, err := c.convert(e.val, false)
+		if err != nil {
+			return nil, err
+		}
+		obj.Fields = append(obj.Fields, &Field{Key: NewKey(e.name), Value: val})
+	}
+	return obj, nil
+}
+
+func mapKeyName(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return "", nil
+		}
+		b, err := tm.MarshalText()
+		return string(b), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", &json.UnsupportedTypeError{Type: k.Type()}
+}
+
+func (c *valueConverter) convertArray(v reflect.Value) (Value, error) {
+	arr := &Array{Elements: make([]*Element, 0, v.Len())}
+	for i := 0; i < v.Len(); i++ {
+		val, err := c.convert(v.Index(i), false)
+		if err != nil {
+			return nil, err
+		}
+		arr.Elements = append(arr.Elements, &Element{Value: val})
+	}
+	return arr, nil
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// clearPos resets the positions of all nodes in a tree, e.g. when they
+// were parsed from a buffer other than the one the tree belongs to.
+func clearPos(n Node) {
+	Inspect(n, func(n Node) bool {
+		switch tn := n.(type) {
+		case *Literal:
+			tn.ValuePos = NoPos
+		case *BadValue:
+			tn.From, tn.To = NoPos, NoPos
+		case *Key:
+			tn.KeyPos = NoPos
+		case *Comment:
+			tn.Slash = NoPos
+		case *Array:
+			tn.Lbrack, tn.Rbrack = NoPos, NoPos
+		case *Object:
+			tn.Lbrace, tn.Rbrace = NoPos, NoPos
+		case *Field:
+			tn.Colon = NoPos
+		}
+		return true
+	})
+}
@@ -0,0 +1,196 @@
+package ast
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+type testInner struct {
+	Name string `json:"name"`
+}
+
+type testEmbedded struct {
+	Embedded string
+	Shadowed string
+}
+
+type testMarshaler struct{}
+
+func (testMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{"custom": [1, 2]}`), nil
+}
+
+type testConfig struct {
+	testEmbedded
+	Zeta     int             `json:"zeta"`
+	Alpha    string          `json:"alpha,omitempty"`
+	Skipped  string          `json:"-"`
+	Shadowed string          `json:"Shadowed"`
+	Quoted   int64           `json:",string"`
+	Inner    *testInner      `json:"inner"`
+	Nil      *testInner      `json:"nil"`
+	List     []interface{}   `json:"list"`
+	Bytes    []byte          `json:"bytes"`
+	Map      map[string]int  `json:"map"`
+	IntMap   map[int]bool    `json:"int_map"`
+	Custom   testMarshaler   `json:"custom"`
+	Addr     net.IP          `json:"addr"`
+	When     time.Time       `json:"when"`
+	Raw      json.RawMessage `json:"raw"`
+	private  int
+	Float    float64           `json:"float"`
+	Strings  map[string]string `json:"strings,omitempty"`
+}
+
+func TestFromValue(t *testing.T) {
+	v := &testConfig{
+		testEmbedded: testEmbedded{Embedded: "e", Shadowed: "hidden"},
+		Zeta:         26,
+		Shadowed:     "visible",
+		Quoted:       42,
+		Inner:        &testInner{Name: "in"},
+		List:         []interface{}{1, "two", nil, 3.5},
+		Bytes:        []byte("hi"),
+		Map:          map[string]int{"b": 2, "a": 1, "c": 3},
+		IntMap:       map[int]bool{10: true, 9: false},
+		Addr:         net.IPv4(127, 0, 0, 1),
+		When:         time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Raw:          json.RawMessage(`{"raw":true}`),
+		private:      1,
+		Float:        1e21,
+	}
+	f, err := FromValue(v, OptionSortMapKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{
+  "Embedded": "e",
+  "zeta": 26,
+  "Shadowed": "visible",
+  "Quoted": "42",
+  "inner": {"name": "in"},
+  "nil": null,
+  "list": [1, "two", null, 3.5],
+  "bytes": "aGk=",
+  "map": {"a": 1, "b": 2, "c": 3},
+  "int_map": {"10": true, "9": false},
+  "custom": {"custom": [1, 2]},
+  "addr": "127.0.0.1",
+  "when": "2020-01-02T03:04:05Z",
+  "raw": {"raw": true},
+  "float": 1e+21,
+}
+`
+	if out := string(FmtJsonr(f)); out != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	// The JSON output must agree with encoding/json.
+	js, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got interface{}
+	if err := json.Unmarshal(js, &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(FmtJson(f), &got); err != nil {
+		t.Fatal(err)
+	}
+	if prettyFmt(want) != prettyFmt(got) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", prettyFmt(want), prettyFmt(got))
+	}
+
+	// Positions from parsing MarshalJSON output must not leak.
+	Inspect(f, func(n Node) bool {
+		if n.Pos().IsValid() {
+			t.Errorf("unexpected position %d on %T", n.Pos(), n)
+		}
+		return true
+	})
+}
+
+func TestFromValueErrors(t *testing.T) {
+	type cycle struct {
+		Next *cycle
+	}
+	c := &cycle{}
+	c.Next = c
+
+	var unsupported *json.UnsupportedTypeError
+	var unsupportedValue *json.UnsupportedValueError
+	if _, err := FromValue(make(chan int)); !errors.As(err, &unsupported) {
+		t.Errorf("expected unsupported type error, got %v", err)
+	}
+	if _, err := FromValue(c); !errors.As(err, &unsupportedValue) {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+	if _, err := FromValue(struct{ N json.Number }{"1.2.3"}); err == nil || err.Error() != `json: invalid number literal "1.2.3"` {
+		t.Errorf("expected invalid number error, got %v", err)
+	}
+	if f, err := FromValue(nil); err != nil || string(FmtJson(f)) != "null\n" {
+		t.Errorf("expected null, got %v", err)
+	}
+}
+
+func TestFromValueSharedSlice(t *testing.T) {
+	// A shorter view of the same backing array is not a cycle.
+	x := make([]interface{}, 2)
+	x[1] = x[:1]
+	f, err := FromValue(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[null, [null]]\n"
+	if out := string(FmtJson(f)); out != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+
+	// The same view is.
+	y := make([]interface{}, 1)
+	y[0] = y
+	var unsupportedValue *json.UnsupportedValueError
+	if _, err := FromValue(y); !errors.As(err, &unsupportedValue) {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+}
+
+func TestFromValueNumber(t *testing.T) {
+	v := struct {
+		N      json.Number `json:"n"`
+		Empty  json.Number `json:"empty"`
+		Quoted json.Number `json:"quoted,string"`
+	}{N: "12.50", Quoted: "3"}
+	f, err := FromValue(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// As encoding/json writes it.
+	expected := `{"n": 12.50, "empty": 0, "quoted": "3"}` + "\n"
+	if out := string(FmtJson(f)); out != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+}
+
+func TestFromValueStringOption(t *testing.T) {
+	n := 7
+	v := struct {
+		Ptr    *int `json:"ptr,string"`
+		Nil    *int `json:"nil,string"`
+		NotQ   int  `json:"notq,stringify"`
+		Second int  `json:"second,omitempty,string"`
+	}{Ptr: &n, NotQ: 3, Second: 2}
+	f, err := FromValue(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// As encoding/json writes it.
+	expected := `{"ptr": "7", "nil": null, "notq": 3, "second": "2"}` + "\n"
+	if out := string(FmtJson(f)); out != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+}
@@ -0,0 +1,79 @@
+package ast
+
+import (
+	"bytes"
+)
+
+// DetectIndent returns the dominant indentation of JSONR source: a tab
+// if most indented lines start with one, otherwise the most common step
+// between the indentation of successive lines as a run of spaces. Only
+// whitespace between tokens is considered, so the content of strings
+// and comments does not count. If nothing is indented, or the input
+// cannot be lexed, the default of two spaces is returned.
+func DetectIndent(in []byte) string {
+	tabLines := 0
+	spaceLines := 0
+	steps := make(map[int]int)
+	prevWidth := 0
+
+	in = bytes.TrimPrefix(in, utf8BOM)
+	s := &scanner{input: in}
+	var t token
+	for s.scan(&t); t.typ != itemEOF; s.scan(&t) {
+		if t.typ == itemError {
+			return string(defaultIndent)
+		}
+		if t.typ != itemWhitespace {
+			continue
+		}
+		val := s.text(&t)
+		nl := bytes.LastIndexByte(val, '\n')
+		if nl < 0 || t.end == len(in) {
+			// Not the start of a line, or trailing whitespace at EOF.
+			continue
+		}
+		lead := val[nl+1:]
+		switch {
+		case len(lead) == 0:
+			prevWidth = 0
+		case lead[0] == '\t':
+			tabLines++
+		default:
+			spaceLines++
+			width := len(lead) - len(bytes.TrimLeft(lead, " "))
+			if width > prevWidth {
+				steps[width-prevWidth]++
+			}
+			prevWidth = width
+		}
+	}
+
+	if tabLines > spaceLines {
+		return "\t"
+	}
+	best, bestCount := 0, 0
+	for step, count := range steps {
+		if count > bestCount || (count == bestCount && step < best) {
+			best, bestCount = step, count
+		}
+	}
+	if best == 0 {
+		return string(defaultIndent)
+	}
+	return string(bytes.Repeat([]byte(" "), best))
+}
+
+var (
+	lf   = []byte("\n")
+	crlf = []byte("\r\n")
+)
+
+// DetectLineEnding returns "\r\n" if most lines of in end that way and
+// "\n" otherwise.
+func DetectLineEnding(in []byte) string {
+	lines := bytes.Count(in, lf)
+	if crlfs := bytes.Count(in, crlf); crlfs > lines-crlfs {
+		return "\r\n"
+	}
+	return "\n"
+}
@@ -0,0 +1,81 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectIndent(t *testing.T) {
+	checkIndent := func(input, expected string) {
+		if indent := DetectIndent([]byte(input)); indent != expected {
+			t.Fatalf("expected indent %q, got %q for: %s", expected, indent, input)
+		}
+	}
+
+	checkIndent(`[1, 2, 3]`, "  ")
+	checkIndent("{\n  \"x\": {\n    \"y\": 1,\n  },\n}\n", "  ")
+	checkIndent("{\n    \"x\": {\n        \"y\": 1,\n    },\n}\n", "    ")
+	checkIndent("{\n\t\"x\": {\n\t\t\"y\": 1,\n\t},\n}\n", "\t")
+	// Comments and strings do not count.
+	checkIndent("/*\n        preamble\n*/\n{\n    // doc\n    \"x\": 1,\n}\n", "    ")
+	checkIndent("// unparseable\n{\n   \"x\": tru,\n}\n", "  ")
+}
+
+func TestFmtIndent(t *testing.T) {
+	input := "{\n    \"x\": [\n        1, // one\n    ],\n}\n"
+	root, err := ParseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(FmtJsonr(root, OptionDetectIndent([]byte(input)))); out != input {
+		t.Fatalf("expected detected indent to be preserved:\n%s\ngot:\n%s", input, out)
+	}
+	expected := "{\n\t\"x\": [\n\t\t1, // one\n\t],\n}\n"
+	if out := string(FmtJsonr(root, OptionIndent("\t"))); out != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	expected = "{\n   \"x\": [\n      1\n   ]\n}\n"
+	if out := string(FmtJson(root, OptionIndentWidth(3), OptionLineWidth(0))); out != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestDetectLineEnding(t *testing.T) {
+	tests := []struct {
+		in, nl string
+	}{
+		{"", "\n"},
+		{"{}", "\n"},
+		{"{\n  \"x\": 1,\n}\n", "\n"},
+		{"{\r\n  \"x\": 1,\r\n}\r\n", "\r\n"},
+		{"{\r\n  \"x\": 1,\n}\n", "\n"},
+		{"{\r\n  \"x\": 1,\r\n}\n", "\r\n"},
+	}
+	for _, tc := range tests {
+		if nl := DetectLineEnding([]byte(tc.in)); nl != tc.nl {
+			t.Errorf("%q: expected %q, got %q", tc.in, tc.nl, nl)
+		}
+	}
+}
+
+func TestFmtLineEnding(t *testing.T) {
+	input := "// doc\r\n{\r\n  \"x\": 1, // one\r\n  \"s\": \"\"\"\r\n    text\r\n    \"\"\",\r\n}\r\n"
+	root, err := ParseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := root.Root.(*Object).Fields[0].Comment.List[0]; string(c.Text) != "// one" {
+		t.Fatalf("expected comment without \\r, got %q", c.Text)
+	}
+	if out := string(FmtJsonr(root, OptionDetectLineEnding([]byte(input)))); out != input {
+		t.Fatalf("expected line endings to be preserved:\n%q\ngot:\n%q", input, out)
+	}
+	expected := strings.Replace(input, "\r\n", "\n", -1)
+	if out := string(FmtJsonr(root)); out != expected {
+		t.Fatalf("expected:\n%q\ngot:\n%q", expected, out)
+	}
+	expected = "{\r\n  \"x\": 1,\r\n  \"s\": \"text\\n\"\r\n}\r\n"
+	if out := string(FmtJson(root, OptionLineEnding("\r\n"), OptionLineWidth(0))); out != expected {
+		t.Fatalf("expected:\n%q\ngot:\n%q", expected, out)
+	}
+}
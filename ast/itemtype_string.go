@@ -23,11 +23,12 @@ func _() {
 	_ = x[itemObjectClose-12]
 	_ = x[itemColon-13]
 	_ = x[itemNumber-14]
+	_ = x[itemIdent-15]
 }
 
-const _itemType_name = "itemErroritemEOFitemWhitespaceitemCommentitemStringitemTrueitemFalseitemNullitemArrayOpenitemArrayCloseitemCommaitemObjectOpenitemObjectCloseitemColonitemNumber"
+const _itemType_name = "itemErroritemEOFitemWhitespaceitemCommentitemStringitemTrueitemFalseitemNullitemArrayOpenitemArrayCloseitemCommaitemObjectOpenitemObjectCloseitemColonitemNumberitemIdent"
 
-var _itemType_index = [...]uint8{0, 9, 16, 30, 41, 51, 59, 68, 76, 89, 103, 112, 126, 141, 150, 160}
+var _itemType_index = [...]uint8{0, 9, 16, 30, 41, 51, 59, 68, 76, 89, 103, 112, 126, 141, 150, 160, 169}
 
 func (i itemType) String() string {
 	if i < 0 || i >= itemType(len(_itemType_index)-1) {
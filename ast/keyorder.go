@@ -0,0 +1,186 @@
+package ast
+
+import (
+	"sort"
+	"strings"
+)
+
+// KeyOrder decides the order of the fields of objects when formatting
+// with OptionKeyOrder. It is called once for each object with the key
+// path of the object and returns a function reporting whether the key
+// named a belongs before the key named b, or nil to keep the fields as
+// they are. Fields with keys that sort equally stay in their original
+// order.
+type KeyOrder func(keyPath []KeyStep) func(a, b string) bool
+
+// byName orders the keys of every object the same way.
+func byName(less func(a, b string) bool) KeyOrder {
+	return func([]KeyStep) func(a, b string) bool {
+		return less
+	}
+}
+
+var (
+	// KeyOrderLexical sorts keys byte by byte.
+	KeyOrderLexical = byName(func(a, b string) bool {
+		return a < b
+	})
+
+	// KeyOrderCaseInsensitive sorts keys ignoring case, so that "b"
+	// comes before "C".
+	KeyOrderCaseInsensitive = byName(func(a, b string) bool {
+		x, y := strings.ToLower(a), strings.ToLower(b)
+		if x != y {
+			return x < y
+		}
+		return a < b
+	})
+
+	// KeyOrderNatural sorts runs of digits within keys by their numeric
+	// value, so that "item2" comes before "item10".
+	KeyOrderNatural = byName(naturalLess)
+)
+
+// KeyOrderPriority puts the keys in names first, in that order, and then
+// the rest as then orders them. A nil then keeps the rest in their
+// original order.
+func KeyOrderPriority(then KeyOrder, names ...string) KeyOrder {
+	rank := make(map[string]int, len(names))
+	for i, name := range names {
+		if _, ok := rank[name]; !ok {
+			rank[name] = i
+		}
+	}
+	return func(keyPath []KeyStep) func(a, b string) bool {
+		return rankedLess(rank, then, keyPath)
+	}
+}
+
+// KeyOrderLike orders keys as they appear in the object at the same key
+// path in ref, such as a reference file of defaults. Objects in arrays
+// follow the element with the same index in ref, or else its first
+// element. Keys that ref lacks come after the others, as then orders
+// them. A nil then keeps them in their original order.
+func KeyOrderLike(ref Node, then KeyOrder) KeyOrder {
+	if f, ok := ref.(*File); ok {
+		ref = f.Root
+	}
+	return func(keyPath []KeyStep) func(a, b string) bool {
+		o, ok := lookupLike(ref, keyPath).(*Object)
+		if !ok {
+			return rankedLess(nil, then, keyPath)
+		}
+		rank := make(map[string]int, len(o.Fields))
+		for i, f := range o.Fields {
+			if _, ok := rank[f.Key.Name]; !ok {
+				rank[f.Key.Name] = i
+			}
+		}
+		return rankedLess(rank, then, keyPath)
+	}
+}
+
+// lookupLike returns the node within v at keyPath, using the first
+// element of arrays that are too short, or nil if there is none.
+func lookupLike(v Node, keyPath []KeyStep) Node {
+	for _, step := range keyPath {
+		switch tv := v.(type) {
+		case *Object:
+			f := tv.Get(step.String())
+			if f == nil {
+				return nil
+			}
+			v = f.Value
+		case *Array:
+			i, _ := step.(ByIdx)
+			if len(tv.Elements) == 0 {
+				return nil
+			}
+			if int(i) >= len(tv.Elements) {
+				i = 0
+			}
+			v = tv.Elements[i].Value
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// rankedLess orders keys in rank before the others, which are ordered by
+// then if it is not nil.
+func rankedLess(rank map[string]int, then KeyOrder, keyPath []KeyStep) func(a, b string) bool {
+	var rest func(a, b string) bool
+	if then != nil {
+		rest = then(keyPath)
+	}
+	if len(rank) == 0 {
+		return rest
+	}
+	return func(a, b string) bool {
+		i, oka := rank[a]
+		j, okb := rank[b]
+		switch {
+		case oka && okb:
+			return i < j
+		case oka || okb:
+			return oka
+		case rest != nil:
+			return rest(a, b)
+		}
+		return false
+	}
+}
+
+// sortFields returns the fields of an object at keyPath in the given
+// order, leaving the object itself as it is.
+func sortFields(order KeyOrder, keyPath []KeyStep, fields []*Field) []*Field {
+	less := order(keyPath)
+	if less == nil {
+		return fields
+	}
+	sorted := make([]*Field, len(fields))
+	copy(sorted, fields)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i].Key.Name, sorted[j].Key.Name)
+	})
+	return sorted
+}
+
+// naturalLess compares strings with runs of digits compared by their
+// numeric value. Strings that differ only in leading zeros are then
+// compared byte by byte.
+func naturalLess(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if !isDigit(a[i]) || !isDigit(b[j]) {
+			if a[i] != b[j] {
+				return a[i] < b[j]
+			}
+			i++
+			j++
+			continue
+		}
+		// Compare runs of digits without leading zeros by length, then
+		// digit by digit.
+		si, sj := i, j
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+		x := strings.TrimLeft(a[si:i], "0")
+		y := strings.TrimLeft(b[sj:j], "0")
+		if len(x) != len(y) {
+			return len(x) < len(y)
+		}
+		if x != y {
+			return x < y
+		}
+	}
+	if len(a)-i != len(b)-j {
+		return len(a)-i < len(b)-j
+	}
+	return a < b
+}
@@ -0,0 +1,105 @@
+package ast
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestSortKeysLeavesTree(t *testing.T) {
+	in := `{"b": 1, "a": {"d": 1, "c": 2}, "long": ["xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx"]}`
+	f, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "a": {"c": 2, "d": 1},
+  "b": 1,
+  "long": [
+    "xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx",
+  ],
+}
+`
+	if out := string(FmtJsonr(f, OptionSortKeys)); out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	if out := string(FmtJson(f.Root, OptionSortKeys, OptionLineWidth(200))); out != `{"a": {"c": 2, "d": 1}, "b": 1, "long": ["xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx"]}` {
+		t.Errorf("unexpected inline output %s", out)
+	}
+	if out := string(FmtJson(f.Root, OptionLineWidth(200))); out != in {
+		t.Errorf("expected the tree to be left as it was, got %s", out)
+	}
+}
+
+func TestSortKeysByName(t *testing.T) {
+	// Keys are sorted by their decoded names, however they are written.
+	f, err := Parse([]byte(`{b: 1, "a": 2, 'c': 3}`), OptionDialect(DialectJSON5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"a": 2, b: 1, 'c': 3}`
+	if out := string(FmtJsonr(f.Root, OptionSortKeys)); out != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+}
+
+func TestKeyOrders(t *testing.T) {
+	names := []string{"item10", "Item3", "b", "item2", "a", "item02", "C", "item", "x1y10", "x1y9"}
+	for _, tc := range []struct {
+		order    KeyOrder
+		expected []string
+	}{
+		{KeyOrderLexical, []string{"C", "Item3", "a", "b", "item", "item02", "item10", "item2", "x1y10", "x1y9"}},
+		{KeyOrderCaseInsensitive, []string{"a", "b", "C", "item", "item02", "item10", "item2", "Item3", "x1y10", "x1y9"}},
+		{KeyOrderNatural, []string{"C", "Item3", "a", "b", "item", "item02", "item2", "item10", "x1y9", "x1y10"}},
+		{KeyOrderPriority(nil, "b", "missing", "item"), []string{"b", "item", "item10", "Item3", "item2", "a", "item02", "C", "x1y10", "x1y9"}},
+		{KeyOrderPriority(KeyOrderNatural, "item10", "C"), []string{"item10", "C", "Item3", "a", "b", "item", "item02", "item2", "x1y9", "x1y10"}},
+	} {
+		less := tc.order(nil)
+		sorted := append([]string(nil), names...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return less(sorted[i], sorted[j])
+		})
+		for i := range sorted {
+			if sorted[i] != tc.expected[i] {
+				t.Errorf("expected %q, got %q", tc.expected, sorted)
+				break
+			}
+		}
+	}
+}
+
+func TestKeyOrderLike(t *testing.T) {
+	ref, err := Parse([]byte(`{
+  "name": "",
+  "servers": [{"host": "", "port": 0}, {"port": 0, "host": ""}],
+  "nested": {"z": 0, "y": 0},
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := Parse([]byte(`{
+  "nested": {"x": 0, "y": 0, "z": 0},
+  "extra": 1,
+  "servers": [{"port": 1, "host": "a"}, {"host": "b", "port": 2}, {"port": 3, "host": "c"}],
+  "name": "x",
+  "another": 2,
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "name": "x",
+  "servers": [
+    {"host": "a", "port": 1},
+    {"port": 2, "host": "b"},
+    {"host": "c", "port": 3},
+  ],
+  "nested": {"z": 0, "y": 0, "x": 0},
+  "another": 2,
+  "extra": 1,
+}
+`
+	if out := string(FmtJsonr(f, OptionKeyOrder(KeyOrderLike(ref, KeyOrderLexical)), OptionLineWidth(40))); out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
@@ -0,0 +1,269 @@
+package ast
+
+// MergeConflict is a part of a file changed differently on each side
+// of a three-way merge.
+type MergeConflict struct {
+	Path string // key path of the conflict, as written by FmtKeyAsPath
+
+	// The conflicting *Field, *Element, root Value or file comments as
+	// they are in each file, or nil where they are missing.
+	Base, Ours, Theirs Node
+}
+
+// Merge3 merges the changes made from base to ours and from base to
+// theirs, field by field, and returns the merged file with any
+// conflicts. A field or element is changed along with its comments, so
+// changes to different keys never conflict, even when adjacent. Fields
+// added by theirs follow the field they follow in theirs.
+//
+// Changes conflict when both sides change the same field or element
+// differently, when one side deletes a field the other changes, and
+// when both sides change an array of different length or with elements
+// added or removed. Objects with duplicate keys are merged as a whole.
+// Where a conflict occurs, the merged file holds our side, or theirs if
+// we deleted it; see OptionConflictMarkers to write both.
+//
+// The merged file shares nodes with its inputs, which are left as they
+// are. Its positions are meaningless.
+func Merge3(base, ours, theirs *File) (*File, []*MergeConflict) {
+	m := &merger{}
+	merged := &File{
+		Doc:     m.fileComments(base.Doc, ours.Doc, theirs.Doc),
+		Root:    ours.Root,
+		Comment: m.fileComments(base.Comment, ours.Comment, theirs.Comment),
+	}
+	if v, ok := m.value(base.Root, ours.Root, theirs.Root); ok {
+		merged.Root = v
+	} else {
+		m.conflict(nil, base.Root, ours.Root, theirs.Root)
+	}
+	return merged, m.conflicts
+}
+
+type merger struct {
+	keyPath   []KeyStep
+	conflicts []*MergeConflict
+}
+
+// conflict records a conflict at step within the current key path, or
+// at the key path itself if step is nil.
+func (m *merger) conflict(step KeyStep, base, ours, theirs Node) {
+	keyPath := m.keyPath
+	if step != nil {
+		keyPath = append(keyPath[:len(keyPath):len(keyPath)], step)
+	}
+	m.conflicts = append(m.conflicts, &MergeConflict{
+		Path:   FmtKeyAsPath(keyPath),
+		Base:   base,
+		Ours:   ours,
+		Theirs: theirs,
+	})
+}
+
+// unchanged reports whether a side left a node as it was, comments and
+// key order included.
+func unchanged(a, b Node) bool {
+	return Equal(a, b, OptionCompareComments, OptionCompareKeyOrder)
+}
+
+// fileComments merges comments at the start or end of a file.
+func (m *merger) fileComments(base, ours, theirs *CommentGroup) *CommentGroup {
+	if g, ok := mergeComments(base, ours, theirs); ok {
+		return g
+	}
+	m.conflict(nil, groupNode(base), groupNode(ours), groupNode(theirs))
+	if ours == nil {
+		return theirs
+	}
+	return ours
+}
+
+// mergeComments merges comments, which conflict if changed on both
+// sides. Any of them may be nil.
+func mergeComments(base, ours, theirs *CommentGroup) (*CommentGroup, bool) {
+	switch {
+	case commentGroupsEqual(ours, theirs), commentGroupsEqual(base, theirs):
+		return ours, true
+	case commentGroupsEqual(base, ours):
+		return theirs, true
+	}
+	return nil, false
+}
+
+// value merges a value present on both sides. A nil base is merged as
+// an empty object, for objects added by both sides. It reports false if
+// the changes conflict at this level, rather than within the value.
+func (m *merger) value(base, ours, theirs Value) (Value, bool) {
+	switch {
+	case unchanged(ours, theirs):
+		return ours, true
+	case base != nil && unchanged(base, ours):
+		return theirs, true
+	case base != nil && unchanged(base, theirs):
+		return ours, true
+	}
+	switch o := ours.(type) {
+	case *Object:
+		t, ok := theirs.(*Object)
+		b, okb := base.(*Object)
+		if ok && (okb || base == nil) {
+			return m.object(b, o, t)
+		}
+	case *Array:
+		t, ok := theirs.(*Array)
+		b, okb := base.(*Array)
+		if ok && okb && len(o.Elements) == len(b.Elements) && len(t.Elements) == len(b.Elements) {
+			return m.array(b, o, t)
+		}
+	}
+	return nil, false
+}
+
+func (m *merger) object(base, ours, theirs *Object) (Value, bool) {
+	if base == nil {
+		base = &Object{}
+	}
+	if hasDuplicateKeys(base) || hasDuplicateKeys(ours) || hasDuplicateKeys(theirs) {
+		return nil, false
+	}
+	doc, okDoc := mergeComments(base.Doc, ours.Doc, theirs.Doc)
+	comment, okComment := mergeComments(base.Comment, ours.Comment, theirs.Comment)
+	if !okDoc || !okComment {
+		return nil, false
+	}
+
+	baseFields, ourFields, theirFields := lastFields(base), lastFields(ours), lastFields(theirs)
+	fields := make([]*Field, 0, len(ours.Fields))
+	for _, of := range ours.Fields {
+		name := of.Key.Name
+		bf, tf := baseFields[name], theirFields[name]
+		switch {
+		case tf != nil:
+			fields = append(fields, m.field(bf, of, tf))
+		case bf == nil:
+			// Added by us.
+			fields = append(fields, of)
+		case unchanged(bf, of):
+			// Deleted by them.
+		default:
+			m.conflict(ByName(name), bf, of, nil)
+			fields = append(fields, of)
+		}
+	}
+	for i, tf := range theirs.Fields {
+		name := tf.Key.Name
+		if ourFields[name] != nil {
+			continue
+		}
+		if bf := baseFields[name]; bf != nil {
+			if unchanged(bf, tf) {
+				continue // deleted by us
+			}
+			m.conflict(ByName(name), bf, nil, tf)
+		}
+		// Insert after the nearest field before it in theirs that we
+		// kept, or else first.
+		at := 0
+		for j := i - 1; j >= 0 && at == 0; j-- {
+			for k, f := range fields {
+				if f.Key.Name == theirs.Fields[j].Key.Name {
+					at = k + 1
+					break
+				}
+			}
+		}
+		fields = append(fields, nil)
+		copy(fields[at+1:], fields[at:])
+		fields[at] = tf
+	}
+
+	merged := *ours
+	merged.Doc = doc
+	merged.Fields = fields
+	merged.Comment = comment
+	return &merged, true
+}
+
+func hasDuplicateKeys(o *Object) bool {
+	return len(lastFields(o)) != len(o.Fields)
+}
+
+// field merges a field present on both sides. On conflict it records
+// the whole field and returns ours.
+func (m *merger) field(base, ours, theirs *Field) *Field {
+	step := ByName(ours.Key.Name)
+	var b *Element
+	if base != nil {
+		b = &Element{Doc: base.Doc, Value: base.Value, Comment: base.Comment}
+	}
+	e, ok := m.member(step, b,
+		&Element{Doc: ours.Doc, Value: ours.Value, Comment: ours.Comment},
+		&Element{Doc: theirs.Doc, Value: theirs.Value, Comment: theirs.Comment})
+	if !ok {
+		m.conflict(step, fieldNode(base), ours, theirs)
+		return ours
+	}
+	merged := *ours
+	merged.Doc = e.Doc
+	merged.Value = e.Value
+	merged.Comment = e.Comment
+	return &merged
+}
+
+func (m *merger) array(base, ours, theirs *Array) (Value, bool) {
+	comment, ok := mergeComments(base.Comment, ours.Comment, theirs.Comment)
+	if !ok {
+		return nil, false
+	}
+	elements := make([]*Element, len(ours.Elements))
+	for i, oe := range ours.Elements {
+		e, ok := m.member(ByIdx(i), base.Elements[i], oe, theirs.Elements[i])
+		if !ok {
+			m.conflict(ByIdx(i), base.Elements[i], oe, theirs.Elements[i])
+			e = oe
+		}
+		elements[i] = e
+	}
+	merged := *ours
+	merged.Elements = elements
+	merged.Comment = comment
+	return &merged, true
+}
+
+// member merges the comments and value of a field or element at step,
+// with a nil base if both sides added it. It reports false if they
+// conflict, and then records no conflicts within it.
+func (m *merger) member(step KeyStep, base, ours, theirs *Element) (*Element, bool) {
+	if base == nil {
+		base = &Element{}
+	}
+	m.keyPath = append(m.keyPath, step)
+	defer func() {
+		m.keyPath = m.keyPath[:len(m.keyPath)-1]
+	}()
+
+	n := len(m.conflicts)
+	doc, okDoc := mergeComments(base.Doc, ours.Doc, theirs.Doc)
+	comment, okComment := mergeComments(base.Comment, ours.Comment, theirs.Comment)
+	v, ok := m.value(base.Value, ours.Value, theirs.Value)
+	if !ok || !okDoc || !okComment {
+		m.conflicts = m.conflicts[:n]
+		return nil, false
+	}
+	return &Element{Doc: doc, Value: v, Comment: comment}, true
+}
+
+// fieldNode and groupNode return a Node that is nil for a nil pointer.
+func fieldNode(f *Field) Node {
+	if f == nil {
+		return nil
+	}
+	return f
+}
+
+func groupNode(g *CommentGroup) Node {
+	if g == nil {
+		return nil
+	}
+	return g
+}
@@ -0,0 +1,126 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		base, ours, theirs string
+		merged             string
+		conflicts          []string
+	}{
+		{
+			// Adjacent changes to different keys, with their comments.
+			base:   "{\n  \"a\": 1,\n  \"b\": 2,\n}\n",
+			ours:   "{\n  // first\n  \"a\": 10,\n  \"b\": 2,\n}\n",
+			theirs: "{\n  \"a\": 1,\n  \"b\": 20, // second\n}\n",
+			merged: "{\n  // first\n  \"a\": 10,\n  \"b\": 20, // second\n}\n",
+		},
+		{
+			// Changes deep within the same field.
+			base:   `{"a": {"x": 1, "y": [1, 2]}}`,
+			ours:   `{"a": {"x": 2, "y": [1, 2]}}`,
+			theirs: `{"a": {"x": 1, "y": [1, 3]}}`,
+			merged: "{\"a\": {\"x\": 2, \"y\": [1, 3]}}\n",
+		},
+		{
+			// Additions follow the field they follow on their side, and
+			// deletions of unchanged fields are kept.
+			base:   `{"a": 1, "b": 2, "c": 3}`,
+			ours:   `{"a": 1, "b": 2, "d": 4}`,
+			theirs: `{"z": 0, "a": 1, "y": 5, "c": 3}`,
+			merged: "{\"z\": 0, \"a\": 1, \"y\": 5, \"d\": 4}\n",
+		},
+		{
+			// The same change on both sides, and objects added by both.
+			base:   `{"a": 1}`,
+			ours:   `{"a": 2, "b": {"x": 1}}`,
+			theirs: `{"a": 2, "b": {"y": 1}}`,
+			merged: "{\"a\": 2, \"b\": {\"y\": 1, \"x\": 1}}\n",
+		},
+		{
+			base:      `{"a": 1, "b": 1}`,
+			ours:      `{"a": 2, "b": 2}`,
+			theirs:    `{"a": 3, "b": 2}`,
+			merged:    "{\n<<<<<<< ours\n  \"a\": 2,\n=======\n  \"a\": 3,\n>>>>>>> theirs\n  \"b\": 2,\n}\n",
+			conflicts: []string{"/a"},
+		},
+		{
+			// Changing a comment conflicts with changing the value.
+			base:      "{\n  // x\n  \"a\": 1,\n}\n",
+			ours:      "{\n  // y\n  \"a\": 1,\n}\n",
+			theirs:    "{\n  // z\n  \"a\": 2,\n}\n",
+			merged:    "{\n<<<<<<< ours\n  // y\n  \"a\": 1,\n=======\n  // z\n  \"a\": 2,\n>>>>>>> theirs\n}\n",
+			conflicts: []string{"/a"},
+		},
+		{
+			base:      `{"a": 1, "b": 1}`,
+			ours:      `{"b": 1}`,
+			theirs:    `{"a": 2, "b": 1}`,
+			merged:    "{\n<<<<<<< ours\n=======\n  \"a\": 2,\n>>>>>>> theirs\n  \"b\": 1,\n}\n",
+			conflicts: []string{"/a"},
+		},
+		{
+			base:      `{"a": [1, 2], "b": 1}`,
+			ours:      `{"a": [1, 2, 3], "b": 1}`,
+			theirs:    `{"a": [0, 2], "b": 1}`,
+			merged:    "{\n<<<<<<< ours\n  \"a\": [1, 2, 3],\n=======\n  \"a\": [0, 2],\n>>>>>>> theirs\n  \"b\": 1,\n}\n",
+			conflicts: []string{"/a"},
+		},
+		{
+			base:      `[1, 2, 3]`,
+			ours:      `[1, 5, 3]`,
+			theirs:    `[1, 6, 4]`,
+			merged:    "[\n  1,\n<<<<<<< ours\n  5,\n=======\n  6,\n>>>>>>> theirs\n  4,\n]\n",
+			conflicts: []string{"/1"},
+		},
+		{
+			base:      "// doc\n[]",
+			ours:      "// ours\n{}",
+			theirs:    "// theirs\n[1]",
+			merged:    "<<<<<<< ours\n// ours\n=======\n// theirs\n>>>>>>> theirs\n<<<<<<< ours\n{}\n=======\n[1]\n>>>>>>> theirs\n",
+			conflicts: []string{"/", "/"},
+		},
+		{
+			// Objects with duplicate keys are merged as a whole.
+			base:      `{"o": {"a": 1, "a": 2}}`,
+			ours:      `{"o": {"a": 1, "a": 3}}`,
+			theirs:    `{"o": {"a": 4, "a": 2}}`,
+			merged:    "{\n<<<<<<< ours\n  \"o\": {\"a\": 1, \"a\": 3},\n=======\n  \"o\": {\"a\": 4, \"a\": 2},\n>>>>>>> theirs\n}\n",
+			conflicts: []string{"/o"},
+		},
+	}
+	for _, tc := range tests {
+		files := make([]*File, 3)
+		for i, in := range []string{tc.base, tc.ours, tc.theirs} {
+			f, err := ParseString(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[i] = f
+		}
+		before := make([]string, 3)
+		for i, f := range files {
+			before[i] = string(FmtJsonr(f))
+		}
+
+		merged, conflicts := Merge3(files[0], files[1], files[2])
+		var paths []string
+		for _, c := range conflicts {
+			paths = append(paths, c.Path)
+		}
+		if !reflect.DeepEqual(paths, tc.conflicts) {
+			t.Errorf("%s: expected conflicts %q, got %q", tc.ours, tc.conflicts, paths)
+		}
+		if out := string(FmtJsonr(merged, OptionConflictMarkers(conflicts, "ours", "theirs"))); out != tc.merged {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", tc.ours, tc.merged, out)
+		}
+		for i, f := range files {
+			if out := string(FmtJsonr(f)); out != before[i] {
+				t.Errorf("%s: input %d was modified:\n%s", tc.ours, i, out)
+			}
+		}
+	}
+}
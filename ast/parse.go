@@ -6,32 +6,18 @@ import (
 )
 
 type parser struct {
-	lex       *lexer
-	item      *item
-	peekItems []*item
+	scan scanner
+	tok  token
 }
 
-func (p *parser) next() *item {
-	if len(p.peekItems) > 0 {
-		p.item = p.peekItems[0]
-		p.peekItems = p.peekItems[1:]
-		return p.item
-	}
-	p.item = p.lex.yield()
-	//fmt.Println("item", p.item)
-	return p.item
-}
-
-func (p *parser) peek() *item {
-	i := p.lex.yield()
-	p.peekItems = append(p.peekItems, i)
-	return i
+func (p *parser) next() {
+	p.scan.scan(&p.tok)
 }
 
 // Parse a JSON string. Objects will be map[string]interface{}, arrays
 // []interface{} and numbers will be float64 for now.
 func (p *parser) parse(input []byte) (interface{}, error) {
-	p.lex = lex("parse-lexer", input)
+	p.scan = scanner{input: input}
 	p.next()
 	p.skipWhitespaceOrComment()
 	return p.parseElement()
@@ -39,7 +25,7 @@ func (p *parser) parse(input []byte) (interface{}, error) {
 
 func (p *parser) skipWhitespaceOrComment() {
 	for {
-		switch p.item.typ {
+		switch p.tok.typ {
 		case itemWhitespace, itemComment:
 			p.next()
 		default:
@@ -49,9 +35,10 @@ func (p *parser) skipWhitespaceOrComment() {
 }
 
 func (p *parser) parseElement() (interface{}, error) {
-	switch p.item.typ {
+	val := p.scan.text(&p.tok)
+	switch p.tok.typ {
 	case itemString:
-		return string(p.item.val[1 : len(p.item.val)-1]), nil
+		return string(val[1 : len(val)-1]), nil
 	case itemTrue:
 		return true, nil
 	case itemFalse:
@@ -63,27 +50,42 @@ func (p *parser) parseElement() (interface{}, error) {
 		// correctly, but perhaps this a concession to informal
 		// compatibility with the scourge that is Javascript?
 		// FIXME(msolo) string copy
-		x, err := strconv.ParseFloat(string(p.item.val), 64)
+		x, err := strconv.ParseFloat(string(val), 64)
 		return x, err
 	case itemArrayOpen:
 		return p.parseArray()
 	case itemObjectOpen:
 		return p.parseObject()
 	case itemError:
-		return nil, fmt.Errorf("parse err: %#v", p.item.val)
+		return nil, p.scan.err
 	default:
-		return nil, fmt.Errorf("unknown type: %v", p.item.typ)
+		return nil, fmt.Errorf("unknown type: %v at position %d", p.tok.typ, p.tok.start)
 	}
 }
 
+// parseSeparator consumes the comma after a member, if any. Without a
+// comma, the member must be the last one.
+func (p *parser) parseSeparator(close itemType) error {
+	p.next()
+	p.skipWhitespaceOrComment()
+	switch p.tok.typ {
+	case itemComma:
+		p.next()
+		return nil
+	case close:
+		return nil
+	case itemError:
+		return p.scan.err
+	}
+	return fmt.Errorf("missing comma at position %d", p.tok.start)
+}
+
 func (p *parser) parseArray() (interface{}, error) {
 	x := make([]interface{}, 0, 16)
+	p.next()
 	for {
-		p.next()
-	onLast:
-		switch p.item.typ {
-		case itemWhitespace, itemComment:
-			continue
+		p.skipWhitespaceOrComment()
+		switch p.tok.typ {
 		case itemArrayClose:
 			return x, nil
 		case itemEOF:
@@ -94,10 +96,8 @@ func (p *parser) parseArray() (interface{}, error) {
 				return nil, err
 			}
 			x = append(x, y)
-			p.next()
-			p.skipWhitespaceOrComment()
-			if p.item.typ != itemComma {
-				goto onLast
+			if err := p.parseSeparator(itemArrayClose); err != nil {
+				return nil, err
 			}
 		}
 	}
@@ -105,22 +105,20 @@ func (p *parser) parseArray() (interface{}, error) {
 
 func (p *parser) parseObject() (interface{}, error) {
 	x := make(map[string]interface{}, 16)
+	p.next()
 	for {
-		p.next()
-	onLast:
-		switch p.item.typ {
-		case itemWhitespace:
-			continue
+		p.skipWhitespaceOrComment()
+		switch p.tok.typ {
 		case itemObjectClose:
 			return x, nil
 		case itemString:
-			key := p.item.val
+			key := p.scan.text(&p.tok)
 			key = key[1 : len(key)-1]
 			p.next()
 			p.skipWhitespaceOrComment()
 
-			if p.item.typ != itemColon {
-				return nil, fmt.Errorf("expected colon delimiter for key token")
+			if p.tok.typ != itemColon {
+				return nil, fmt.Errorf("expected colon delimiter for key token at position %d", p.tok.start)
 			}
 
 			p.next()
@@ -132,14 +130,13 @@ func (p *parser) parseObject() (interface{}, error) {
 			}
 			x[string(key)] = val
 
-			p.next()
-			p.skipWhitespaceOrComment()
-
-			if p.item.typ != itemComma {
-				goto onLast
+			if err := p.parseSeparator(itemObjectClose); err != nil {
+				return nil, err
 			}
+		case itemError:
+			return nil, p.scan.err
 		default:
-			return nil, fmt.Errorf("invalid key token %v", p.item)
+			return nil, fmt.Errorf("invalid key token %v at position %d", p.tok.typ, p.tok.start)
 		}
 	}
 }
@@ -64,4 +64,10 @@ func TestParse(t *testing.T) {
 
 	//FIXME(msolo) check back-to-back docs
 
+	for _, in := range []string{`[1 2]`, `{"a": 1 "b": 2}`, `{"a" 1}`, `[1,`, `nul`} {
+		if _, err := (&parser{}).parse([]byte(in)); err == nil {
+			t.Errorf("input %q: expected error", in)
+		}
+	}
+
 }
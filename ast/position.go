@@ -0,0 +1,185 @@
+package ast
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Pos is a compact encoding of a source position within a FileSet,
+// much like go/token.Pos. It can be converted into a Position for a
+// human-readable file, line and column.
+//
+// The zero value NoPos means no position is known, e.g. for nodes
+// constructed by hand rather than by the parser.
+type Pos int
+
+const NoPos Pos = 0
+
+func (p Pos) IsValid() bool {
+	return p != NoPos
+}
+
+// Position describes a location in a source file. Line and Column are
+// 1-based; Column counts bytes, not runes.
+type Position struct {
+	Filename string
+	Offset   int // byte offset, starting at 0
+	Line     int
+	Column   int
+}
+
+func (pos Position) IsValid() bool {
+	return pos.Line > 0
+}
+
+// String returns one of:
+//
+//	file:line:column
+//	line:column
+//	file
+//	-
+func (pos Position) String() string {
+	s := pos.Filename
+	if pos.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// SourceFile maps the positions of one file added to a FileSet back to
+// offsets, lines and columns.
+type SourceFile struct {
+	name  string
+	base  int
+	size  int
+	lines []int // offset of the first byte of each line
+}
+
+func (f *SourceFile) Name() string {
+	return f.name
+}
+
+// Base is the Pos of the first byte in the file.
+func (f *SourceFile) Base() int {
+	return f.base
+}
+
+func (f *SourceFile) Size() int {
+	return f.size
+}
+
+func (f *SourceFile) LineCount() int {
+	return len(f.lines)
+}
+
+// Pos returns the Pos for a byte offset in the file.
+func (f *SourceFile) Pos(offset int) Pos {
+	if offset < 0 || offset > f.size {
+		panic(fmt.Sprintf("invalid offset %d for file %q of size %d", offset, f.name, f.size))
+	}
+	return Pos(f.base + offset)
+}
+
+// Offset returns the byte offset of a Pos in the file.
+func (f *SourceFile) Offset(p Pos) int {
+	if int(p) < f.base || int(p) > f.base+f.size {
+		panic(fmt.Sprintf("invalid Pos %d for file %q", p, f.name))
+	}
+	return int(p) - f.base
+}
+
+// Position returns the file, line and column of a Pos in the file.
+func (f *SourceFile) Position(p Pos) Position {
+	if !p.IsValid() {
+		return Position{}
+	}
+	offset := f.Offset(p)
+	i := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+	return Position{
+		Filename: f.name,
+		Offset:   offset,
+		Line:     i + 1,
+		Column:   offset - f.lines[i] + 1,
+	}
+}
+
+func newSourceFile(filename string, base int, src []byte) *SourceFile {
+	lines := make([]int, 1, 64)
+	for i, b := range src {
+		if b == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &SourceFile{
+		name:  filename,
+		base:  base,
+		size:  len(src),
+		lines: lines,
+	}
+}
+
+// FileSet assigns each added file a disjoint range of Pos values so
+// that positions from many parsed files can share a single table. It
+// is safe for concurrent use.
+type FileSet struct {
+	mu    sync.RWMutex
+	base  int
+	files []*SourceFile
+	last  *SourceFile
+}
+
+func NewFileSet() *FileSet {
+	// Start at 1 so that the zero value of Pos means no position.
+	return &FileSet{base: 1}
+}
+
+// AddFile registers the content of a file and returns its SourceFile.
+// Positions in the file start at the base returned by SourceFile.Base.
+func (s *FileSet) AddFile(filename string, src []byte) *SourceFile {
+	f := newSourceFile(filename, 0, src)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f.base = s.base
+	// +1 so the position just past the end of one file is not the start
+	// of the next.
+	s.base += len(src) + 1
+	s.files = append(s.files, f)
+	s.last = f
+	return f
+}
+
+// File returns the file containing the position p, or nil.
+func (s *FileSet) File(p Pos) *SourceFile {
+	if !p.IsValid() {
+		return nil
+	}
+	s.mu.RLock()
+	if f := s.last; f != nil && f.base <= int(p) && int(p) <= f.base+f.size {
+		s.mu.RUnlock()
+		return f
+	}
+	i := sort.Search(len(s.files), func(i int) bool { return s.files[i].base > int(p) }) - 1
+	var f *SourceFile
+	if i >= 0 && int(p) <= s.files[i].base+s.files[i].size {
+		f = s.files[i]
+	}
+	s.mu.RUnlock()
+	return f
+}
+
+// Position converts a Pos into a Position, or the zero Position if p
+// does not belong to any file in the set.
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(p)
+	}
+	return Position{}
+}
@@ -0,0 +1,74 @@
+package ast
+
+import "testing"
+
+func TestNodePositions(t *testing.T) {
+	in := []byte(`// doc
+{
+  "x": [1, true],
+  "y": null, /* trailer */
+}
+`)
+	fset := NewFileSet()
+	// Add an unrelated file first so positions are not simply offsets.
+	fset.AddFile("other.jsonr", []byte("{}\n"))
+	file, err := ParseFile(fset, "test.jsonr", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRange := func(name string, pn Node, pos, end string) {
+		if p := fset.Position(pn.Pos()).String(); p != pos {
+			t.Errorf("%s: expected pos %s, got %s", name, pos, p)
+		}
+		if e := fset.Position(pn.End()).String(); e != end {
+			t.Errorf("%s: expected end %s, got %s", name, end, e)
+		}
+	}
+
+	root := file.Root.(*Object)
+	x := root.Fields[0]
+	arr := x.Value.(*Array)
+	y := root.Fields[1]
+
+	checkRange("file", file, "test.jsonr:1:1", "test.jsonr:5:2")
+	checkRange("doc", file.Doc, "test.jsonr:1:1", "test.jsonr:1:7")
+	checkRange("root", root, "test.jsonr:2:1", "test.jsonr:5:2")
+	checkRange("field x", x, "test.jsonr:3:3", "test.jsonr:3:17")
+	checkRange("key x", x.Key, "test.jsonr:3:3", "test.jsonr:3:6")
+	checkRange("array", arr, "test.jsonr:3:8", "test.jsonr:3:17")
+	checkRange("element true", arr.Elements[1], "test.jsonr:3:12", "test.jsonr:3:16")
+	checkRange("field y", y, "test.jsonr:4:3", "test.jsonr:4:12")
+	checkRange("trailer", y.Comment.List[0], "test.jsonr:4:14", "test.jsonr:4:27")
+
+	if p := fset.Position(x.Colon).String(); p != "test.jsonr:3:6" {
+		t.Errorf("expected colon at test.jsonr:3:6, got %s", p)
+	}
+	if f := fset.File(root.Pos()); f == nil || f.Name() != "test.jsonr" || f.Offset(root.Pos()) != 7 {
+		t.Errorf("expected root at offset 7 of test.jsonr, got %#v", f)
+	}
+}
+
+func TestFileSet(t *testing.T) {
+	fset := NewFileSet()
+	a := fset.AddFile("a", []byte("ab\ncd"))
+	b := fset.AddFile("b", []byte(""))
+	c := fset.AddFile("c", []byte("\n\nx"))
+
+	checkPosition := func(p Pos, expected string) {
+		if s := fset.Position(p).String(); s != expected {
+			t.Errorf("pos %d: expected %s, got %s", p, expected, s)
+		}
+	}
+	checkPosition(NoPos, "-")
+	checkPosition(a.Pos(0), "a:1:1")
+	checkPosition(a.Pos(4), "a:2:2")
+	checkPosition(a.Pos(5), "a:2:3") // EOF
+	checkPosition(b.Pos(0), "b:1:1")
+	checkPosition(c.Pos(2), "c:3:1")
+	checkPosition(Pos(c.Base()+c.Size()+1), "-")
+
+	if c.LineCount() != 3 {
+		t.Errorf("expected 3 lines, got %d", c.LineCount())
+	}
+}
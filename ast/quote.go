@@ -0,0 +1,172 @@
+package ast
+
+import (
+	"bytes"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// unquote decodes a JSON string literal, including its surrounding
+// quotes, a multi-line string or a single-quoted string from
+// DialectSingleQuotes. Invalid UTF-8 and unpaired surrogates are
+// replaced with utf8.RuneError as encoding/json does. It reports false
+// if the literal is malformed.
+func unquote(s []byte) (string, bool) {
+	b, ok := unquoteBytes(s)
+	return string(b), ok
+}
+
+func unquoteBytes(s []byte) ([]byte, bool) {
+	return unquoteMode(s, false)
+}
+
+// unquoteExact is like unquoteBytes, but fails rather than replace
+// invalid UTF-8 or an unpaired surrogate with U+FFFD.
+func unquoteExact(s []byte) ([]byte, bool) {
+	return unquoteMode(s, true)
+}
+
+func unquoteMode(s []byte, exact bool) ([]byte, bool) {
+	if len(s) < 2 || (s[0] != '"' && s[0] != '\'') || s[len(s)-1] != s[0] {
+		return nil, false
+	}
+	if isTextBlock(s) {
+		return unescape(textBlockValue(s), exact)
+	}
+	return unescape(s[1:len(s)-1], exact)
+}
+
+// unescape decodes the escape sequences in the content of a string.
+// Unless exact, invalid UTF-8 and unpaired surrogates become U+FFFD.
+func unescape(s []byte, exact bool) ([]byte, bool) {
+	// Fast path: nothing to decode.
+	if bytes.IndexByte(s, '\\') < 0 && utf8.Valid(s) {
+		return s, true
+	}
+
+	b := make([]byte, 0, len(s)+2*utf8.UTFMax)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\':
+			i++
+			if i >= len(s) {
+				return nil, false
+			}
+			switch s[i] {
+			case '"', '\\', '/', '\'':
+				b = append(b, s[i])
+			case 'b':
+				b = append(b, '\b')
+			case 'f':
+				b = append(b, '\f')
+			case 'n':
+				b = append(b, '\n')
+			case 'r':
+				b = append(b, '\r')
+			case 't':
+				b = append(b, '\t')
+			case 'u':
+				r, ok := unhex4(s[i+1:])
+				if !ok {
+					return nil, false
+				}
+				i += 4
+				if utf16.IsSurrogate(r) {
+					r2, ok := rune(-1), false
+					if i+2 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+						r2, ok = unhex4(s[i+3:])
+					}
+					if dec := utf16.DecodeRune(r, r2); ok && dec != utf8.RuneError {
+						r = dec
+						i += 6
+					} else if exact {
+						return nil, false
+					} else {
+						r = utf8.RuneError
+					}
+				}
+				b = append(b, string(r)...)
+			default:
+				return nil, false
+			}
+			i++
+		case c < utf8.RuneSelf:
+			b = append(b, c)
+			i++
+		default:
+			r, size := utf8.DecodeRune(s[i:])
+			if exact && r == utf8.RuneError && size == 1 {
+				return nil, false
+			}
+			i += size
+			b = append(b, string(r)...)
+		}
+	}
+	return b, true
+}
+
+// unhex4 decodes the four hex digits of a \u escape.
+func unhex4(s []byte) (rune, bool) {
+	if len(s) < 4 {
+		return -1, false
+	}
+	var r rune
+	for _, c := range s[:4] {
+		switch {
+		case '0' <= c && c <= '9':
+			c = c - '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return -1, false
+		}
+		r = r*16 + rune(c)
+	}
+	return r, true
+}
+
+const hexDigits = "0123456789abcdef"
+
+// quote encodes s as a JSON string literal with minimal escaping: only
+// quotes, backslashes and control characters are escaped. Invalid UTF-8
+// is replaced with utf8.RuneError.
+func quote(s string) []byte {
+	b := make([]byte, 0, len(s)+2)
+	b = append(b, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				b = append(b, '\\', c)
+			case c == '\b':
+				b = append(b, '\\', 'b')
+			case c == '\f':
+				b = append(b, '\\', 'f')
+			case c == '\n':
+				b = append(b, '\\', 'n')
+			case c == '\r':
+				b = append(b, '\\', 'r')
+			case c == '\t':
+				b = append(b, '\\', 't')
+			case c < 0x20:
+				b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				b = append(b, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, string(utf8.RuneError)...)
+		} else {
+			b = append(b, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(b, '"')
+}
@@ -0,0 +1,49 @@
+package ast
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestUnquote(t *testing.T) {
+	checkUnquote := func(in, expected string) {
+		s, ok := unquote([]byte(in))
+		if !ok {
+			t.Fatalf("unquote failed: %s", in)
+		}
+		if s != expected {
+			t.Fatalf("unquote %s: expected %q, got %q", in, expected, s)
+		}
+	}
+	checkUnquote(`""`, "")
+	checkUnquote(`"plain"`, "plain")
+	checkUnquote(`"\"\\\/\b\f\n\r\t"`, "\"\\/\b\f\n\r\t")
+	checkUnquote(`"é世"`, "é世")
+	checkUnquote(`"😀"`, "😀")
+	checkUnquote(`"\ud83d\ude00"`, "😀")
+	checkUnquote(`"\ud83d"`, "�")
+	checkUnquote("\"\xff\"", "�")
+
+	for _, in := range []string{``, `"`, `"\"`, `"\x"`, `"\u12"`, `"\u12g4"`} {
+		if _, ok := unquote([]byte(in)); ok {
+			t.Errorf("expected unquote to fail: %s", in)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	checkQuote := func(in, expected string) {
+		if q := string(quote(in)); q != expected {
+			t.Fatalf("quote %q: expected %s, got %s", in, expected, q)
+		}
+		if s, ok := unquote([]byte(expected)); !ok || (s != in && utf8.ValidString(in)) {
+			t.Fatalf("quote %q does not round trip: %q", in, s)
+		}
+	}
+	checkQuote("", `""`)
+	checkQuote("plain", `"plain"`)
+	checkQuote("a/b", `"a/b"`)
+	checkQuote("\"\\\b\f\n\r\t\x00\x1f", `"\"\\\b\f\n\r\t\u0000\u001f"`)
+	checkQuote("é世😀", `"é世😀"`)
+	checkQuote("\xff", `"�"`)
+}
@@ -0,0 +1,540 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:generate stringer -type=itemType
+type itemType int
+
+const (
+	itemError itemType = iota // error occurred; see scanner.err
+
+	itemEOF
+	itemWhitespace
+	itemComment
+	itemString
+	itemTrue
+	itemFalse
+	itemNull
+	itemArrayOpen
+	itemArrayClose
+	itemComma
+	itemObjectOpen
+	itemObjectClose
+	itemColon
+	itemNumber
+	itemIdent // an unquoted key, with DialectIdentifierKeys
+)
+
+var (
+	commentStart    = []byte("//")
+	endRangeComment = []byte("*/")
+	tripleQuote     = []byte(`"""`)
+)
+
+// SyntaxError describes malformed JSONR input.
+type SyntaxError struct {
+	Msg    string
+	Offset int // byte offset of the error in the input
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Offset)
+}
+
+// token is a single lexical token. A token is typically reused between
+// calls to scanner.scan so that scanning does not allocate.
+type token struct {
+	typ   itemType
+	start int  // offset of the token in the input
+	end   int  // offset just past the token
+	plain bool // a quoted string whose content is ASCII with no escapes
+}
+
+// text returns the bytes of t, a slice of the input rather than a copy.
+// Offsets are kept instead of a slice so that filling in a token never
+// needs a write barrier.
+func (s *scanner) text(t *token) []byte {
+	return s.input[t.start:t.end]
+}
+
+// scanner splits JSONR input into tokens one at a time. It does not
+// check the grammar, only the syntax of each token; the caller is
+// expected to drive it from a recursive descent parser. Scanning does
+// not allocate except to report an error.
+type scanner struct {
+	input   []byte
+	pos     int
+	err     *SyntaxError
+	strict  bool // reject what RFC 8259 forbids, except comments and trailing commas
+	dialect Dialect
+
+	noLeadingZeros bool // reject numbers with leading zeros even if not strict
+}
+
+// scan reads the next token into t. At the end of the input it
+// produces itemEOF. On malformed input it produces itemError and
+// records the error in s.err; scanning does not advance past it.
+func (s *scanner) scan(t *token) {
+	t.start, t.end, t.plain = s.pos, s.pos, false
+	if s.err != nil {
+		t.typ = itemError
+		return
+	}
+	if s.pos >= len(s.input) {
+		t.typ = itemEOF
+		return
+	}
+
+	c := s.input[s.pos]
+	if s.dialect != 0 && s.scanDialect(t, c) {
+		if t.typ != itemError {
+			t.end = s.pos
+		}
+		return
+	}
+
+	switch c {
+	case ' ', '\t', '\n', '\r':
+		s.pos++
+		for s.pos < len(s.input) {
+			c = s.input[s.pos]
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				break
+			}
+			s.pos++
+		}
+		t.typ = itemWhitespace
+	case '"':
+		t.typ, t.plain = s.scanString('"')
+	case '{':
+		s.pos++
+		t.typ = itemObjectOpen
+	case '}':
+		s.pos++
+		t.typ = itemObjectClose
+	case '[':
+		s.pos++
+		t.typ = itemArrayOpen
+	case ']':
+		s.pos++
+		t.typ = itemArrayClose
+	case ',':
+		s.pos++
+		t.typ = itemComma
+	case ':':
+		s.pos++
+		t.typ = itemColon
+	case 't':
+		t.typ = s.scanKeyword("true", itemTrue)
+	case 'f':
+		t.typ = s.scanKeyword("false", itemFalse)
+	case 'n':
+		t.typ = s.scanKeyword("null", itemNull)
+	case '/':
+		t.typ = s.scanComment()
+	case '+':
+		t.typ = s.errorf(s.pos, "malformed number: %s", badToken(s.input[s.pos:]))
+	default:
+		if c == '-' || ('0' <= c && c <= '9') {
+			t.typ = s.scanNumber()
+		} else {
+			t.typ = s.errorf(s.pos, "invalid element: %s", badToken(s.input[s.pos:]))
+		}
+	}
+	if t.typ != itemError {
+		t.end = s.pos
+	}
+}
+
+// scanDialect scans a token that begins with c if it is only valid
+// in the dialect. It reports false if c begins the same token as in
+// plain JSONR.
+func (s *scanner) scanDialect(t *token, c byte) bool {
+	switch {
+	case s.dialect&DialectIdentifierKeys != 0 && (c == '$' || c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= utf8.RuneSelf):
+		t.typ = s.scanIdent()
+	case s.dialect&DialectSingleQuotes != 0 && c == '\'':
+		t.typ, t.plain = s.scanString('\'')
+	case s.dialect&DialectHashComments != 0 && c == '#':
+		t.typ = s.scanLineComment()
+	case s.dialect&DialectPlusSign != 0 && c == '+',
+		s.dialect&DialectDecimalPoints != 0 && c == '.',
+		s.dialect&DialectInfinityNaN != 0 && (c == 'I' || c == 'N'):
+		t.typ = s.scanNumber()
+	default:
+		return false
+	}
+	return true
+}
+
+// scanIdent scans an identifier, which may turn out to be a keyword or,
+// with DialectInfinityNaN, a number.
+func (s *scanner) scanIdent() itemType {
+	start := s.pos
+	for s.pos < len(s.input) {
+		r, size := rune(s.input[s.pos]), 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRune(s.input[s.pos:])
+		}
+		if !(r == '$' || r == '_' || unicode.IsLetter(r) || s.pos > start && unicode.IsDigit(r)) {
+			break
+		}
+		s.pos += size
+	}
+	switch string(s.input[start:s.pos]) {
+	case "":
+		return s.errorf(start, "invalid element: %s", badToken(s.input[start:]))
+	case "true":
+		return itemTrue
+	case "false":
+		return itemFalse
+	case "null":
+		return itemNull
+	case "Infinity", "NaN":
+		if s.dialect&DialectInfinityNaN != 0 {
+			return itemNumber
+		}
+	}
+	return itemIdent
+}
+
+// resync clears the error that produced the error token t and moves
+// past the malformed input so that a parser can recover and continue.
+// The bad input becomes the text of t.
+func (s *scanner) resync(t *token) {
+	s.err = nil
+	i := t.start
+	switch {
+	case i >= len(s.input):
+	case bytes.HasPrefix(s.input[i:], tripleQuote):
+		// Skip a whole multi-line string, or what remains of the input.
+		if j := bytes.Index(s.input[i+len(tripleQuote):], tripleQuote); j >= 0 {
+			i += j + 2*len(tripleQuote)
+		} else {
+			i = len(s.input)
+		}
+	case s.input[i] == '"':
+		// Strings cannot span lines, so the end of the line bounds an
+		// unterminated one.
+		for i++; i < len(s.input) && s.input[i] != '\n'; i++ {
+			if s.input[i] == '\\' {
+				i++
+			} else if s.input[i] == '"' {
+				i++
+				break
+			}
+		}
+	case s.input[i] == '/' && i+1 < len(s.input) && s.input[i+1] == '*':
+		// An unterminated comment runs to the end of the input.
+		i = len(s.input)
+	default:
+	skip:
+		for i++; i < len(s.input); i++ {
+			switch s.input[i] {
+			case ' ', '\t', '\n', '\r', ',', ':', '[', ']', '{', '}', '"', '/':
+				break skip
+			}
+		}
+	}
+	s.pos = min(i, len(s.input))
+	t.end = s.pos
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxExcerpt is the most source quoted in an error message.
+const maxExcerpt = 20
+
+// excerpt quotes a token for an error message, up to the end of its
+// first line and at most maxExcerpt bytes.
+func excerpt(tok []byte) string {
+	if i := bytes.IndexAny(tok, "\r\n"); i >= 0 {
+		tok = tok[:i]
+	}
+	if len(tok) > maxExcerpt {
+		n := maxExcerpt
+		for n > 0 && !utf8.RuneStart(tok[n]) {
+			n--
+		}
+		return strconv.Quote(string(tok[:n])) + "..."
+	}
+	return strconv.Quote(string(tok))
+}
+
+// badToken quotes the malformed token at the start of src for an error
+// message, up to the space or delimiter that ends it.
+func badToken(src []byte) string {
+	n := 0
+	for n < len(src) {
+		r, size := utf8.DecodeRune(src[n:])
+		if n > 0 && (unicode.IsSpace(r) || bytes.ContainsRune([]byte(",:[]{}\"'/"), r)) {
+			break
+		}
+		n += size
+	}
+	return excerpt(src[:n])
+}
+
+func (s *scanner) errorf(offset int, format string, args ...interface{}) itemType {
+	s.err = &SyntaxError{Msg: fmt.Sprintf(format, args...), Offset: offset}
+	return itemError
+}
+
+func (s *scanner) scanKeyword(kw string, typ itemType) itemType {
+	if end := s.pos + len(kw); end > len(s.input) || string(s.input[s.pos:end]) != kw {
+		return s.errorf(s.pos, "failed parsing %s", kw)
+	}
+	s.pos += len(kw)
+	return typ
+}
+
+// acceptDigits consumes a run of decimal digits and reports whether
+// there were any.
+func (s *scanner) acceptDigits() bool {
+	return s.acceptDigitsFunc(isDigit)
+}
+
+// acceptDigitsFunc is like acceptDigits for the digits of another base.
+// With DialectNumericSeparators a single _ may separate digits.
+func (s *scanner) acceptDigitsFunc(digit func(c byte) bool) bool {
+	start := s.pos
+	for s.pos < len(s.input) {
+		if digit(s.input[s.pos]) {
+			s.pos++
+		} else if s.input[s.pos] == '_' && s.dialect&DialectNumericSeparators != 0 &&
+			s.pos > start && s.pos+1 < len(s.input) && digit(s.input[s.pos+1]) {
+			s.pos++
+		} else {
+			break
+		}
+	}
+	return s.pos > start
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func (s *scanner) acceptByte(b byte) bool {
+	if s.pos < len(s.input) && s.input[s.pos] == b {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+func (s *scanner) scanNumber() itemType {
+	if !s.acceptByte('-') && s.dialect&DialectPlusSign != 0 {
+		s.acceptByte('+')
+	}
+	if s.dialect != 0 {
+		if typ, ok := s.scanDialectNumber(); ok {
+			return typ
+		}
+	}
+	// The spec says leading zeros are verboten, but that seems pointlessly
+	// pedantic when parsing and stripping. Decoding rejects them, as
+	// encoding/json does, rather than guess what they mean.
+	if (s.strict || s.noLeadingZeros) && s.pos+1 < len(s.input) && s.input[s.pos] == '0' && '0' <= s.input[s.pos+1] && s.input[s.pos+1] <= '9' {
+		return s.errorf(s.pos, "leading zero in number")
+	}
+	if !s.acceptDigits() {
+		return s.errorf(s.pos, "malformed integer number")
+	}
+	if s.acceptByte('.') && !s.acceptDigits() && s.dialect&DialectDecimalPoints == 0 {
+		return s.errorf(s.pos, "malformed real number")
+	}
+	if !s.acceptExponent() {
+		return s.errorf(s.pos, "malformed exponent")
+	}
+	return itemNumber
+}
+
+// scanDialectNumber scans the rest of a number after its sign if it is
+// one only valid in the dialect: Infinity, NaN, hexadecimal or with a
+// leading decimal point.
+func (s *scanner) scanDialectNumber() (itemType, bool) {
+	rest := s.input[s.pos:]
+	switch {
+	case s.dialect&DialectInfinityNaN != 0 && bytes.HasPrefix(rest, []byte("Infinity")):
+		s.pos += len("Infinity")
+	case s.dialect&DialectInfinityNaN != 0 && bytes.HasPrefix(rest, []byte("NaN")):
+		s.pos += len("NaN")
+	case s.dialect&DialectHexNumbers != 0 && len(rest) > 1 && rest[0] == '0' && (rest[1] == 'x' || rest[1] == 'X'):
+		s.pos += 2
+		if !s.acceptDigitsFunc(isHexDigit) {
+			return s.errorf(s.pos, "malformed hexadecimal number"), true
+		}
+	case s.dialect&DialectDecimalPoints != 0 && len(rest) > 0 && rest[0] == '.':
+		s.pos++
+		if !s.acceptDigits() {
+			return s.errorf(s.pos, "malformed real number"), true
+		}
+		if !s.acceptExponent() {
+			return s.errorf(s.pos, "malformed exponent"), true
+		}
+	default:
+		return 0, false
+	}
+	return itemNumber, true
+}
+
+// acceptExponent consumes an optional exponent and reports whether it
+// was well formed.
+func (s *scanner) acceptExponent() bool {
+	if s.acceptByte('e') || s.acceptByte('E') {
+		if !s.acceptByte('+') {
+			s.acceptByte('-')
+		}
+		return s.acceptDigits()
+	}
+	return true
+}
+
+// scanString scans a quoted string. It also reports whether the
+// string is plain, so that its content can be used without unquoting.
+func (s *scanner) scanString(quote byte) (itemType, bool) {
+	if quote == '"' && bytes.HasPrefix(s.input[s.pos:], tripleQuote) {
+		return s.scanTextBlock(), false
+	}
+	start := s.pos
+	plain := true
+	s.pos++ // swallow leading quote
+	for s.pos < len(s.input) {
+		c := s.input[s.pos]
+		switch {
+		case c == quote:
+			s.pos++
+			return itemString, plain
+		case c == '\\':
+			plain = false
+			if !s.scanEscape() {
+				return itemError, false
+			}
+		case c < 0x20:
+			return s.errorf(s.pos, "invalid literal character %q: control characters from \\u0000 - \\u001f must be escaped", c), false
+		case c >= utf8.RuneSelf:
+			plain = false
+			if !s.strict {
+				s.pos++
+				break
+			}
+			r, size := utf8.DecodeRune(s.input[s.pos:])
+			if r == utf8.RuneError && size == 1 {
+				return s.errorf(s.pos, "invalid UTF-8 in string"), false
+			}
+			s.pos += size
+		default:
+			s.pos++
+		}
+	}
+	return s.errorf(start, "unexpected EOF scanning string"), false
+}
+
+// scanEscape scans an escape sequence in a string. It reports false if
+// the sequence is invalid.
+func (s *scanner) scanEscape() bool {
+	s.pos++ // swallow \
+	if s.pos >= len(s.input) {
+		return true // the string is unterminated
+	}
+	switch s.input[s.pos] {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+		s.pos++
+	case '\'':
+		if s.dialect&DialectSingleQuotes == 0 {
+			s.errorf(s.pos, "invalid escaped character")
+			return false
+		}
+		s.pos++
+	case 'u':
+		s.pos++
+		if _, ok := unhex4(s.input[s.pos:]); !ok {
+			s.errorf(s.pos, "invalid unicode escape sequence")
+			return false
+		}
+		s.pos += 4
+	default:
+		s.errorf(s.pos, "invalid escaped character")
+		return false
+	}
+	return true
+}
+
+// scanTextBlock scans a multi-line string: """ and a line break, then
+// lines of text up to the closing """. See textBlockValue.
+func (s *scanner) scanTextBlock() itemType {
+	start := s.pos
+	if s.strict {
+		return s.errorf(start, "multi-line strings are not allowed in strict mode")
+	}
+	s.pos += len(tripleQuote)
+	for s.pos < len(s.input) && (s.input[s.pos] == ' ' || s.input[s.pos] == '\t' || s.input[s.pos] == '\r') {
+		s.pos++
+	}
+	if !s.acceptByte('\n') {
+		return s.errorf(s.pos, "multi-line string must start on the line after \"\"\"")
+	}
+	for s.pos < len(s.input) {
+		c := s.input[s.pos]
+		switch {
+		case c == '"' && bytes.HasPrefix(s.input[s.pos:], tripleQuote):
+			s.pos += len(tripleQuote)
+			return itemString
+		case c == '\\':
+			if !s.scanEscape() {
+				return itemError
+			}
+		case c < 0x20 && c != '\n' && c != '\t' && c != '\r':
+			return s.errorf(s.pos, "invalid literal character %q: control characters other than tab and newline must be escaped", c)
+		default:
+			s.pos++
+		}
+	}
+	return s.errorf(start, "unexpected EOF scanning multi-line string")
+}
+
+// scanLineComment scans the rest of a line comment.
+func (s *scanner) scanLineComment() itemType {
+	if i := bytes.IndexByte(s.input[s.pos:], '\n'); i >= 0 {
+		// don't include trailing \n or \r\n
+		s.pos += i
+		if s.input[s.pos-1] == '\r' {
+			s.pos--
+		}
+	} else {
+		s.pos = len(s.input)
+	}
+	return itemComment
+}
+
+func (s *scanner) scanComment() itemType {
+	start := s.pos
+	s.pos++ // swallow /
+	switch {
+	case s.acceptByte('/'):
+		return s.scanLineComment()
+	case s.acceptByte('*'):
+		if i := bytes.Index(s.input[s.pos:], endRangeComment); i >= 0 {
+			s.pos += i + len(endRangeComment)
+			return itemComment
+		}
+		return s.errorf(start, "unexpected EOF scanning comment")
+	}
+	return s.errorf(start, "invalid element: %s", badToken(s.input[start:]))
+}
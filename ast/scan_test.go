@@ -5,34 +5,43 @@ import (
 	"testing"
 )
 
-func lexToSlice(t *testing.T, s string) []*item {
-	l := lex("test-lex", []byte(s))
-	items := make([]*item, 0, 16)
+// testToken is a scanned token with a copy of its text, or the error
+// message for itemError.
+type testToken struct {
+	typ itemType
+	val []byte
+}
+
+func scanToSlice(t *testing.T, s string) []testToken {
+	sc := &scanner{input: []byte(s)}
+	tokens := make([]testToken, 0, 16)
+	var tok token
 	for {
-		i := l.yield()
-		items = append(items, i)
-		if i.typ == itemEOF || i.typ == itemError {
-			// if i.typ == itemError {
-			// 	t.Log(i)
-			// }
-			return items
+		sc.scan(&tok)
+		val := sc.text(&tok)
+		if tok.typ == itemError {
+			val = []byte(sc.err.Msg)
+		}
+		tokens = append(tokens, testToken{tok.typ, val})
+		if tok.typ == itemEOF || tok.typ == itemError {
+			return tokens
 		}
 	}
 }
 
-func checkItem(t *testing.T, i *item, val string) {
+func checkItem(t *testing.T, i testToken, val string) {
 	if !bytes.Equal(i.val, []byte(val)) {
 		t.Fatalf("expected %s: got %s", val, i.val)
 	}
 }
 
-func checkItemHasPrefix(t *testing.T, i *item, val string) {
+func checkItemHasPrefix(t *testing.T, i testToken, val string) {
 	if !bytes.HasPrefix(i.val, []byte(val)) {
 		t.Fatalf("expected %s: got %s", val, i.val)
 	}
 }
 
-func checkTokenVals(t *testing.T, items []*item, val ...string) {
+func checkTokenVals(t *testing.T, items []testToken, val ...string) {
 	// +1 for implicit EOF
 	if len(items) != len(val)+1 {
 		t.Fatalf("expected %d tokens: got %d", len(val)+1, len(items))
@@ -45,114 +54,130 @@ func checkTokenVals(t *testing.T, items []*item, val ...string) {
 }
 
 func TestElement(t *testing.T) {
-	tl := lexToSlice(t, `"tiptop"`)
+	tl := scanToSlice(t, `"tiptop"`)
 	checkItem(t, tl[0], `"tiptop"`)
 
-	tl = lexToSlice(t, `"tiptop1""tiptop2"`)
+	tl = scanToSlice(t, `"tiptop1""tiptop2"`)
 	checkItem(t, tl[0], `"tiptop1"`)
 	checkItem(t, tl[1], `"tiptop2"`)
 
-	tl = lexToSlice(t, `"tiptop1" "tiptop2"`)
+	tl = scanToSlice(t, `"tiptop1" "tiptop2"`)
 	checkItem(t, tl[0], `"tiptop1"`)
 	checkItem(t, tl[2], `"tiptop2"`)
 
-	tl = lexToSlice(t, `null`)
+	tl = scanToSlice(t, `null`)
 	checkItem(t, tl[0], `null`)
 
-	tl = lexToSlice(t, `true`)
+	tl = scanToSlice(t, `true`)
 	checkItem(t, tl[0], `true`)
 
-	tl = lexToSlice(t, `false`)
+	tl = scanToSlice(t, `false`)
 	checkItem(t, tl[0], `false`)
 
-	tl = lexToSlice(t, `nul`)
+	tl = scanToSlice(t, `nul`)
 	checkItem(t, tl[0], `failed parsing null`)
 
-	tl = lexToSlice(t, `treu`)
+	tl = scanToSlice(t, `treu`)
 	checkItem(t, tl[0], `failed parsing true`)
 
-	tl = lexToSlice(t, `fals`)
+	tl = scanToSlice(t, `fals`)
 	checkItem(t, tl[0], `failed parsing false`)
 
 	// FIXME(msolo) This might not be valid, it's not totally clear. jq
 	// can't parse it, that's for sure.
-	tl = lexToSlice(t, `nullnull`)
+	tl = scanToSlice(t, `nullnull`)
 	checkItem(t, tl[0], `null`)
 	checkItem(t, tl[1], `null`)
 }
 
 func TestElementString(t *testing.T) {
-	tl := lexToSlice(t, `"1\t2"`)
+	tl := scanToSlice(t, `"1\t2"`)
 	checkItem(t, tl[0], `"1\t2"`)
-	tl = lexToSlice(t, `"1\x2"`)
+	tl = scanToSlice(t, `"1\x2"`)
 	checkItem(t, tl[0], `invalid escaped character`)
-	tl = lexToSlice(t, `"1\u000"`)
+	tl = scanToSlice(t, `"1\u000"`)
 	checkItem(t, tl[0], `invalid unicode escape sequence`)
 }
 
 func TestElementNull(t *testing.T) {
-	tl := lexToSlice(t, `null`)
+	tl := scanToSlice(t, `null`)
 	checkItem(t, tl[0], `null`)
 }
 
 func TestElementNumber(t *testing.T) {
-	tl := lexToSlice(t, `0`)
+	tl := scanToSlice(t, `0`)
 	checkItem(t, tl[0], `0`)
-	tl = lexToSlice(t, `1.1`)
+	tl = scanToSlice(t, `1.1`)
 	checkItem(t, tl[0], `1.1`)
-	tl = lexToSlice(t, `-1.1`)
+	tl = scanToSlice(t, `-1.1`)
 	checkItem(t, tl[0], `-1.1`)
-	tl = lexToSlice(t, `1.1e01`)
+	tl = scanToSlice(t, `1.1e01`)
 	checkItem(t, tl[0], `1.1e01`)
-	tl = lexToSlice(t, `1.1E01`)
+	tl = scanToSlice(t, `1.1E01`)
 	checkItem(t, tl[0], `1.1E01`)
-	tl = lexToSlice(t, `1.1e-1`)
+	tl = scanToSlice(t, `1.1e-1`)
 	checkItem(t, tl[0], `1.1e-1`)
 }
 
 func TestElementInvalidNumber(t *testing.T) {
-	tl := lexToSlice(t, `+1.1e01`)
+	tl := scanToSlice(t, `+1.1e01`)
 	checkItemHasPrefix(t, tl[len(tl)-1], `malformed number`)
 }
 
+func TestErrorExcerpt(t *testing.T) {
+	// Errors quote the bad token alone, on one line.
+	for in, expected := range map[string]string{
+		"x}\n{":                      `invalid element: "x"`,
+		"*/ c":                       `invalid element: "*"`,
+		"abcdefghijklmnopqrstuvwxyz": `invalid element: "abcdefghijklmnopqrst"...`,
+		"€€€€€€€":                    `invalid element: "€€€€€€"...`,
+	} {
+		tl := scanToSlice(t, in)
+		checkItem(t, tl[len(tl)-1], expected)
+	}
+	if err := Unmarshal([]byte("[1 \"\"\"\n  a\n  b\n  \"\"\"]"), new(interface{})); err == nil || err.Error() != `expected comma or close: "\"\"\"" at position 3` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
 func TestEmptyArray(t *testing.T) {
-	tl := lexToSlice(t, `[]`)
+	tl := scanToSlice(t, `[]`)
 	checkItem(t, tl[0], `[`)
 	checkItem(t, tl[1], `]`)
 
-	tl = lexToSlice(t, `[ ]`)
+	tl = scanToSlice(t, `[ ]`)
 	checkItem(t, tl[0], `[`)
 	checkItem(t, tl[2], `]`)
 }
 
 func TestArray(t *testing.T) {
-	tl := lexToSlice(t, `[null]`)
+	tl := scanToSlice(t, `[null]`)
 	checkItem(t, tl[0], `[`)
 	checkItem(t, tl[2], `]`)
 
-	tl = lexToSlice(t, `["1", "2"]`)
+	tl = scanToSlice(t, `["1", "2"]`)
 	checkTokenVals(t, tl, `[`, `"1"`, `,`, ` `, `"2"`, `]`)
 }
 
 func TestEmptyObject(t *testing.T) {
-	tl := lexToSlice(t, `{}`)
+	tl := scanToSlice(t, `{}`)
 	checkTokenVals(t, tl, `{`, `}`)
 
-	tl = lexToSlice(t, `{ }`)
+	tl = scanToSlice(t, `{ }`)
 	checkItem(t, tl[0], `{`)
 	checkItem(t, tl[2], `}`)
 }
 
 func TestObject(t *testing.T) {
-	tl := lexToSlice(t, `{"a":null}`)
+	tl := scanToSlice(t, `{"a":null}`)
 	checkTokenVals(t, tl, `{`, `"a"`, `:`, `null`, `}`)
 
-	tl = lexToSlice(t, `{"a":null,"b":null}`)
+	tl = scanToSlice(t, `{"a":null,"b":null}`)
 	checkTokenVals(t, tl, `{`, `"a"`, `:`, `null`, `,`, `"b"`, `:`, `null`, `}`)
 }
 
 func TestLineComment(t *testing.T) {
-	tl := lexToSlice(t, `{
+	tl := scanToSlice(t, `{
 //}
 }
 `)
@@ -160,7 +185,7 @@ func TestLineComment(t *testing.T) {
 }
 
 func TestFieldComment(t *testing.T) {
-	tl := lexToSlice(t, `{
+	tl := scanToSlice(t, `{
   "x": null,
   // 1
   // 2
@@ -174,42 +199,42 @@ func TestFieldComment(t *testing.T) {
 }
 
 func TestRangeComment(t *testing.T) {
-	tl := lexToSlice(t, `{/**/}`)
+	tl := scanToSlice(t, `{/**/}`)
 	checkItem(t, tl[1], `/**/`)
 }
 
 func TestMultilineRangeComment(t *testing.T) {
-	tl := lexToSlice(t, `{/*
+	tl := scanToSlice(t, `{/*
 */}`)
 	checkItem(t, tl[1], `/*
 */`)
 }
 
 func TestRangeCommentInString(t *testing.T) {
-	tl := lexToSlice(t, `"/**/"`)
+	tl := scanToSlice(t, `"/**/"`)
 	checkItem(t, tl[0], `"/**/"`)
 }
 
 func TestNestedQuoteInString(t *testing.T) {
-	tl := lexToSlice(t, `"\""`)
+	tl := scanToSlice(t, `"\""`)
 	checkItem(t, tl[0], `"\""`)
 }
 
 func TestNoCommentTerminator(t *testing.T) {
-	tl := lexToSlice(t, `{/*}`)
+	tl := scanToSlice(t, `{/*}`)
 	if tl[len(tl)-1].typ != itemError {
 		t.Error("expected a parsing error - no comment terminator")
 	}
 }
 
 func TestCommentedObject(t *testing.T) {
-	tl := lexToSlice(t, `// c1
+	tl := scanToSlice(t, `// c1
 {
   // c2
   "a": null, // c3
 } /* c4 */
 `)
-	tlm := make([]*item, 0, len(tl))
+	tlm := make([]testToken, 0, len(tl))
 	// Let's just check the "meaningful" tokens for now.
 	for _, i := range tl {
 		if i.typ != itemWhitespace {
@@ -218,3 +243,24 @@ func TestCommentedObject(t *testing.T) {
 	}
 	checkTokenVals(t, tlm, `// c1`, `{`, `// c2`, `"a"`, `:`, `null`, `,`, `// c3`, `}`, `/* c4 */`)
 }
+
+func TestScanAllocs(t *testing.T) {
+	in := []byte(`// c1
+{
+  /* c2 */
+  "a": [1.5e3, -2, true, false, null, "s\t\u00e9"], // c3
+}
+`)
+	allocs := testing.AllocsPerRun(100, func() {
+		sc := &scanner{input: in}
+		var tok token
+		for sc.scan(&tok); tok.typ != itemEOF; sc.scan(&tok) {
+			if tok.typ == itemError {
+				t.Fatal(sc.err)
+			}
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("scanning allocated %v times per run", allocs)
+	}
+}
@@ -0,0 +1,221 @@
+package ast
+
+import (
+	"bytes"
+	"io"
+)
+
+const minStreamRead = 4096
+
+// StreamDecoder reads a stream of JSONR values, such as JSONR Lines,
+// one value at a time. Values may be separated by whitespace and
+// comments; comments on the same line as a value belong to it and
+// other comments to the value that follows them.
+type StreamDecoder struct {
+	r       io.Reader
+	options []ParseOption
+	dialect Dialect
+
+	buf []byte
+	off int   // start of the unread data in buf
+	pos int   // offset of buf[0] in the stream
+	eof bool  // r has no more data
+	err error // sticky read error
+
+	started bool // a byte order mark at the start has been dropped
+}
+
+func NewStreamDecoder(r io.Reader, options ...ParseOption) *StreamDecoder {
+	return &StreamDecoder{r: r, options: options, dialect: newParser(1, options).dialect}
+}
+
+// More reports whether there is another value in the stream. It
+// returns true if the next call to Decode or DecodeFile would return
+// an error other than io.EOF.
+func (d *StreamDecoder) More() bool {
+	_, err := d.next()
+	return err != io.EOF
+}
+
+// DecodeFile reads the next value and its comments as an AST. Node
+// positions are relative to the start of the stream, as if it had been
+// read in full and parsed with ParseAll. At the end of the stream it
+// returns io.EOF; comments after the last value are ignored.
+func (d *StreamDecoder) DecodeFile() (*File, error) {
+	end, err := d.next()
+	if err != nil {
+		return nil, err
+	}
+	f, err := newParser(1+d.pos+d.off, d.options).Parse(d.buf[d.off:end])
+	if _, ok := err.(ErrorList); ok {
+		// With OptionRecover the value is usable despite its errors.
+		d.off = end
+		return f, err
+	}
+	if err != nil {
+		return nil, d.offsetError(err)
+	}
+	d.off = end
+	return f, nil
+}
+
+// Decode reads the next value into v following the rules of Unmarshal,
+// or of UnmarshalDialect if the decoder has a Dialect.
+// At the end of the stream it returns io.EOF.
+func (d *StreamDecoder) Decode(v interface{}) error {
+	end, err := d.next()
+	if err != nil {
+		return err
+	}
+	if err := unmarshalUTF8(d.buf[d.off:end], v, d.dialect); err != nil {
+		if _, ok := err.(*SyntaxError); ok {
+			return d.offsetError(err)
+		}
+		// Like json.Decoder, skip over a value that has the wrong type.
+		d.off = end
+		return err
+	}
+	d.off = end
+	return nil
+}
+
+// offsetError makes the offset of a syntax error relative to the
+// start of the stream.
+func (d *StreamDecoder) offsetError(err error) error {
+	if serr, ok := err.(*SyntaxError); ok {
+		return &SyntaxError{Msg: serr.Msg, Offset: d.pos + d.off + serr.Offset}
+	}
+	return err
+}
+
+// next returns the end in buf of the next value, reading more of the
+// stream as needed. It does not consume the value.
+func (d *StreamDecoder) next() (int, error) {
+	for !d.started {
+		if len(d.buf) >= len(utf8BOM) || d.eof {
+			// As in ParseAll, offsets do not count a byte order mark.
+			d.buf = bytes.TrimPrefix(d.buf, utf8BOM)
+			d.started = true
+		} else if err := d.fill(); err != nil {
+			return 0, err
+		}
+	}
+	for {
+		n, ok, err := d.scanValue()
+		if ok {
+			return d.off + n, nil
+		}
+		if d.eof {
+			if err != nil {
+				return 0, d.offsetError(err)
+			}
+			return 0, io.EOF
+		}
+		// An error may just be a token cut short by the end of the
+		// buffer, so only report it once there is nothing more to read.
+		if err := d.fill(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// scanValue finds the length of the next value in the unread data,
+// including any comments before it and the rest of its line. It
+// reports false if there is no complete value yet.
+func (d *StreamDecoder) scanValue() (int, bool, error) {
+	s := scanner{input: d.buf[d.off:], dialect: d.dialect}
+	var t token
+	depth, started := 0, false
+	for {
+		s.scan(&t)
+		switch {
+		case t.typ == itemError:
+			return 0, false, s.err
+		case t.typ == itemEOF:
+			if d.eof && started {
+				// Let the parser report whatever is wrong with it.
+				return len(s.input), true, nil
+			}
+			return 0, false, nil
+		case !d.eof && t.end == len(s.input) && mayContinue(&s, &t):
+			// The token may continue in data not read yet.
+			return 0, false, nil
+		}
+
+		if started && depth == 0 {
+			// The value is complete; take the rest of its line.
+			switch t.typ {
+			case itemWhitespace:
+				if i := bytes.IndexByte(s.text(&t), '\n'); i >= 0 {
+					return t.start + i, true, nil
+				}
+			case itemComment:
+				if isLineComment(s.text(&t)) {
+					return t.end, true, nil
+				}
+			default:
+				return t.start, true, nil
+			}
+			continue
+		}
+
+		switch t.typ {
+		case itemWhitespace, itemComment:
+		case itemArrayOpen, itemObjectOpen:
+			depth++
+			started = true
+		case itemArrayClose, itemObjectClose:
+			if depth > 0 {
+				depth--
+			}
+			started = true
+		default:
+			started = true
+		}
+	}
+}
+
+// mayContinue reports whether more input could extend t. Whitespace
+// that ends the line of a complete value does not need to wait for
+// more input, so line-at-a-time streams are decoded promptly.
+func mayContinue(s *scanner, t *token) bool {
+	switch t.typ {
+	case itemNumber, itemIdent:
+		return true
+	case itemWhitespace:
+		return bytes.IndexByte(s.text(t), '\n') < 0
+	case itemComment:
+		return isLineComment(s.text(t))
+	}
+	return false
+}
+
+// fill reads more of the stream into buf. Data already in buf is never
+// moved or overwritten, since the ASTs of earlier values refer to it.
+func (d *StreamDecoder) fill() error {
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.buf) == cap(d.buf) {
+		unread := d.buf[d.off:]
+		size := 2 * len(unread)
+		if size < minStreamRead {
+			size = minStreamRead
+		}
+		buf := make([]byte, len(unread), size)
+		copy(buf, unread)
+		d.pos += d.off
+		d.buf, d.off = buf, 0
+	}
+	n, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+	d.buf = d.buf[:len(d.buf)+n]
+	switch err {
+	case nil:
+	case io.EOF:
+		d.eof = true
+	default:
+		d.err = err
+		return err
+	}
+	return nil
+}
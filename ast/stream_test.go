@@ -0,0 +1,117 @@
+package ast
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var testLines = `// Header.
+{"id": 1, "tags": ["a"]} // One.
+// Two.
+{"id": 2,
+ "tags": []}
+3 /* Three. */ // Still three.
+
+"four"
+// Trailer.
+`
+
+// summarize describes each file as its doc, value and trailing comment.
+func summarize(files []*File) string {
+	var out []string
+	for _, f := range files {
+		var doc, comment []string
+		if f.Doc != nil {
+			for _, c := range f.Doc.List {
+				doc = append(doc, string(c.Text))
+			}
+		}
+		if f.Comment != nil {
+			for _, c := range f.Comment.List {
+				comment = append(comment, string(c.Text))
+			}
+		}
+		out = append(out, strings.Join(doc, " ")+"|"+strings.TrimSpace(string(FmtJson(f.Root)))+"|"+strings.Join(comment, " "))
+	}
+	return strings.Join(out, "\n")
+}
+
+func TestParseAll(t *testing.T) {
+	files, err := ParseAll([]byte(testLines))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `// Header.|{"id": 1, "tags": ["a"]}|// One.
+// Two.|{"id": 2, "tags": []}|
+|3|/* Three. */ // Still three.
+|"four"|// Trailer.`
+	if out := summarize(files); out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	if _, err := Parse([]byte(testLines)); err == nil {
+		t.Error("expected Parse to reject more than one value")
+	}
+	if files, err := ParseAll([]byte(" // Nothing.\n")); err != nil || len(files) != 0 {
+		t.Errorf("expected no values, got %d %v", len(files), err)
+	}
+}
+
+func TestStreamDecoder(t *testing.T) {
+	// Read a byte at a time so that values straddle reads.
+	d := NewStreamDecoder(iotest.OneByteReader(strings.NewReader(testLines)))
+	var files []*File
+	for d.More() {
+		f, err := d.DecodeFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	// Comments after the last value are dropped.
+	expected := `// Header.|{"id": 1, "tags": ["a"]}|// One.
+// Two.|{"id": 2, "tags": []}|
+|3|/* Three. */ // Still three.
+|"four"|`
+	if out := summarize(files); out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	if p := files[1].Root.Pos(); p != Pos(1+strings.Index(testLines, "{\"id\": 2")) {
+		t.Errorf("expected position relative to the stream, got %d", p)
+	}
+	if _, err := d.DecodeFile(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+
+	type record struct {
+		ID   int
+		Tags []string
+	}
+	d = NewStreamDecoder(strings.NewReader("{\"id\": 1}\n{\"id\": 2, \"tags\": [\"x\",],}\n"))
+	var records []record
+	for {
+		var r record
+		if err := d.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 2 || records[1].ID != 2 || records[1].Tags[0] != "x" {
+		t.Errorf("unexpected records: %v", records)
+	}
+
+	d = NewStreamDecoder(strings.NewReader("1\n[2,\n3 4]\n"))
+	var v interface{}
+	if err := d.Decode(&v); err != nil || v != 1.0 {
+		t.Fatalf("expected 1, got %v %v", v, err)
+	}
+	var serr *SyntaxError
+	if err := d.Decode(&v); !errors.As(err, &serr) || serr.Offset != 8 {
+		t.Errorf("expected syntax error at offset 8, got %v", err)
+	}
+}
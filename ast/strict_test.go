@@ -0,0 +1,76 @@
+package ast
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The files in testdata/rfc8259 follow the naming of JSONTestSuite:
+// y_ files must be accepted, n_ files rejected and i_ files may go
+// either way. Comments and trailing commas are accepted even in strict
+// mode, so the y_jsonr_ files are cases that JSONTestSuite rejects.
+func TestStrictConformance(t *testing.T) {
+	paths, err := filepath.Glob("testdata/rfc8259/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no test files")
+	}
+	for _, path := range paths {
+		name := filepath.Base(path)
+		in, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stripped, stripErr := StripStrict(in)
+		_, parseErr := Parse(in, OptionStrict)
+		var v interface{}
+		unmarshalErr := UnmarshalStrict(in, &v)
+		if _, ok := unmarshalErr.(*SyntaxError); !ok && unmarshalErr != nil && !strings.HasPrefix(name, "n_") {
+			// A number out of range for float64 is valid syntax.
+			unmarshalErr = nil
+		}
+
+		switch {
+		case strings.HasPrefix(name, "y_"):
+			for _, err := range []error{stripErr, parseErr, unmarshalErr} {
+				if err != nil {
+					t.Errorf("%s: expected success, got %v", name, err)
+				}
+			}
+			if stripErr == nil && !json.Valid(stripped) {
+				t.Errorf("%s: stripped to invalid JSON %s", name, stripped)
+			}
+		case strings.HasPrefix(name, "n_"):
+			for _, err := range []error{stripErr, parseErr, unmarshalErr} {
+				if _, ok := err.(*SyntaxError); !ok {
+					t.Errorf("%s: expected syntax error, got %v", name, err)
+				}
+			}
+		case strings.HasPrefix(name, "i_"):
+			// Whichever way it goes, all three should agree.
+			if (stripErr == nil) != (parseErr == nil) || (stripErr == nil) != (unmarshalErr == nil) {
+				t.Errorf("%s: inconsistent results: %v, %v, %v", name, stripErr, parseErr, unmarshalErr)
+			}
+		default:
+			t.Errorf("%s: unexpected file name", name)
+		}
+	}
+}
+
+func TestStrictOnly(t *testing.T) {
+	// Relaxed mode accepts these.
+	for _, in := range []string{`[012]`, `-01.5`, "[\"\xff\"]", `{}{}`, ``} {
+		if _, err := Strip([]byte(in)); err != nil {
+			t.Errorf("input %q: %v", in, err)
+		}
+		if _, err := StripStrict([]byte(in)); err == nil {
+			t.Errorf("input %q: expected error in strict mode", in)
+		}
+	}
+}
@@ -9,75 +9,166 @@ import (
 )
 
 type stripper struct {
-	lex      *lexer
-	buf      *bytes.Buffer
-	prevItem *item
+	scan    scanner
+	tok     token
+	buf     []byte
+	strict  bool
+	dialect Dialect
 }
 
 // Strip all JSONR enhancements and emit clean JSON.
 func (p *stripper) Strip(input []byte) ([]byte, error) {
-	p.lex = lex("parse-lexer", input)
-	p.lex.emitter = p.emitter
-	for {
-		i := p.lex.yield()
-		if i.typ == itemEOF {
-			return p.buf.Bytes(), nil
-		}
+	input, err := sourceUTF8(input, p.strict)
+	if err != nil {
+		return nil, err
 	}
+	return p.strip(input)
 }
 
-func (p *stripper) emitter(t itemType, val []byte, start int) {
-	if p.prevItem == nil {
-		p.prevItem = &item{t, val, start}
-		return
+// strip is Strip for input that has already been through sourceUTF8.
+func (p *stripper) strip(input []byte) ([]byte, error) {
+	p.scan = scanner{input: input, strict: p.strict, dialect: p.dialect}
+	p.buf = make([]byte, 0, len(input))
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.strict {
+		// Exactly one value.
+		if err := p.value(); err != nil {
+			return nil, err
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.typ != itemEOF {
+			return nil, p.errorf("invalid element after top-level value: %s", excerpt(p.scan.text(&p.tok)))
+		}
+		return p.buf, nil
 	}
+	for n := 0; p.tok.typ != itemEOF; n++ {
+		if n > 0 {
+			// Keep a stream of values, such as JSONR Lines, readable by
+			// json.Decoder.
+			p.buf = append(p.buf, '\n')
+		}
+		if err := p.value(); err != nil {
+			return nil, err
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	return p.buf, nil
+}
 
-	if t == itemWhitespace || t == itemComment {
-		return
+// next advances to the next token that is not whitespace or a comment.
+func (p *stripper) next() error {
+	for {
+		p.scan.scan(&p.tok)
+		switch p.tok.typ {
+		case itemWhitespace, itemComment:
+			continue
+		case itemError:
+			return p.scan.err
+		}
+		return nil
 	}
+}
 
-	switch p.prevItem.typ {
+func (p *stripper) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Msg: fmt.Sprintf(format, args...), Offset: p.tok.start}
+}
+
+func (p *stripper) value() error {
+	switch p.tok.typ {
+	case itemTrue, itemFalse, itemNull:
+		p.buf = append(p.buf, p.scan.text(&p.tok)...)
+		return nil
 	case itemString:
-		p.buf.Write(p.prevItem.val)
-	case itemTrue:
-		p.buf.WriteString("true")
-	case itemFalse:
-		p.buf.WriteString("false")
-	case itemNull:
-		p.buf.WriteString("null")
+		if text := p.scan.text(&p.tok); p.dialect != 0 || isTextBlock(text) {
+			p.buf = append(p.buf, jsonString(text)...)
+		} else {
+			p.buf = append(p.buf, text...)
+		}
+		return nil
 	case itemNumber:
-		p.buf.Write(p.prevItem.val)
+		if p.dialect != 0 {
+			p.buf = append(p.buf, jsonNumber(p.scan.text(&p.tok))...)
+		} else {
+			p.buf = append(p.buf, p.scan.text(&p.tok)...)
+		}
+		return nil
 	case itemArrayOpen:
-		p.buf.WriteString("[")
+		return p.members(itemArrayClose, "array")
 	case itemObjectOpen:
-		p.buf.WriteString("{")
-	case itemWhitespace, itemComment:
-	case itemArrayClose:
-		p.buf.WriteString("]")
-	case itemComma:
-		if t != itemArrayClose && t != itemObjectClose {
-			p.buf.WriteString(",")
-		}
-	case itemObjectClose:
-		p.buf.WriteString("}")
-	case itemColon:
-		p.buf.WriteString(":")
+		return p.members(itemObjectClose, "object")
 	case itemEOF:
-		return
-	case itemError:
-		panic(fmt.Errorf("parse err: %#v", p.prevItem.val))
-	default:
-		panic(fmt.Errorf("programmer unknown type: %v", p.prevItem.typ))
-
+		return p.errorf("unexpected EOF")
 	}
+	return p.errorf("invalid element: %s", excerpt(p.scan.text(&p.tok)))
+}
 
-	p.prevItem.typ = t
-	p.prevItem.val = val
-	p.prevItem.start = start
+// members copies an array or object, dropping any trailing comma. The
+// current token is the opening bracket.
+func (p *stripper) members(close itemType, kind string) error {
+	p.buf = append(p.buf, p.scan.text(&p.tok)...)
+	if err := p.next(); err != nil {
+		return err
+	}
+	for n := 0; p.tok.typ != close; n++ {
+		if p.tok.typ == itemEOF {
+			return p.errorf("unclosed %s", kind)
+		}
+		if n > 0 {
+			p.buf = append(p.buf, ',')
+		}
+		if close == itemObjectClose {
+			if p.tok.typ != itemString && !p.scan.isIdentKey(&p.tok) {
+				return p.errorf("object key must be string: %s", excerpt(p.scan.text(&p.tok)))
+			}
+			if text := p.scan.text(&p.tok); p.dialect != 0 || isTextBlock(text) {
+				p.buf = append(p.buf, jsonKey(text)...)
+			} else {
+				p.buf = append(p.buf, text...)
+			}
+			if err := p.next(); err != nil {
+				return err
+			}
+			if p.tok.typ != itemColon {
+				return p.errorf("object member has no : delimiter")
+			}
+			p.buf = append(p.buf, ':')
+			if err := p.next(); err != nil {
+				return err
+			}
+		}
+		if err := p.value(); err != nil {
+			return err
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+		if p.tok.typ == itemComma {
+			if err := p.next(); err != nil {
+				return err
+			}
+		} else if p.tok.typ != close {
+			return p.errorf("unclosed %s", kind)
+		}
+	}
+	p.buf = append(p.buf, p.scan.text(&p.tok)...)
+	return nil
 }
 
 func Strip(in []byte) ([]byte, error) {
-	return (&stripper{buf: bytes.NewBuffer(make([]byte, 0, 256))}).Strip(in)
+	return (&stripper{}).Strip(in)
+}
+
+// StripStrict is like Strip, but apart from comments and trailing
+// commas accepts only what RFC 8259 allows: exactly one value, numbers
+// without leading zeros and strings of valid UTF-8.
+func StripStrict(in []byte) ([]byte, error) {
+	return (&stripper{strict: true}).Strip(in)
 }
 
 func StripReader(r io.Reader) ([]byte, error) {
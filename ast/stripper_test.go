@@ -0,0 +1,36 @@
+package ast
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStrip(t *testing.T) {
+	for in, expected := range map[string]string{
+		`null`:                                 `null`,
+		` /* c */ [1, 2,] // c`:                `[1,2]`,
+		"{\n  \"a\": 1, // c\n  \"b\": [],\n}": `{"a":1,"b":[]}`,
+		`{"a": {"b": "é\"",},}`:                `{"a":{"b":"é\""}}`,
+		`{}{}`:                                 "{}\n{}",
+		"1 2 // c\n3":                          "1\n2\n3",
+		``:                                     ``,
+	} {
+		out, err := Strip([]byte(in))
+		if err != nil {
+			t.Errorf("input %q: %v", in, err)
+		} else if string(out) != expected {
+			t.Errorf("input %q: expected %s, got %s", in, expected, out)
+		}
+	}
+
+	for _, in := range []string{
+		`[1 2]`, `{"a" 1}`, `{"a": 1 "b": 2}`, `[,]`, `{,}`, `{1: 2}`, `nul`,
+		`{"a": [1, 2}`, `[1,`, `"unterminated`, `/* open`, `+1`, "\"ctl\x01\"",
+	} {
+		_, err := Strip([]byte(in))
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("input %q: expected syntax error, got %v", in, err)
+		}
+	}
+}
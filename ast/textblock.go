@@ -0,0 +1,102 @@
+package ast
+
+import (
+	"bytes"
+)
+
+// A multi-line string, or text block, is written between """ on the
+// line before the text and """ after it. The text is indented with the
+// surrounding JSONR, so the indentation common to its lines is not part
+// of the string, nor is whitespace at the end of a line:
+//
+//	"query": """
+//	  SELECT *
+//	  FROM t
+//	  """,
+//
+// is "SELECT *\nFROM t\n". The closing """ counts towards the common
+// indentation when it is on a line of its own, in which case the string
+// ends with a newline; otherwise it does not. Escape sequences are
+// decoded after the indentation is removed.
+
+// isTextBlock reports whether a string literal is a text block.
+func isTextBlock(s []byte) bool {
+	// Any other string starting with "" is the empty string.
+	return len(s) > 2 && s[0] == '"' && s[1] == '"'
+}
+
+// textBlockLines returns the lines of a text block after the opening
+// """, with the closing """ removed from the last.
+func textBlockLines(s []byte) [][]byte {
+	s = s[len(tripleQuote) : len(s)-len(tripleQuote)]
+	lines := bytes.Split(s, []byte("\n"))
+	return lines[1:]
+}
+
+func isBlank(line []byte) bool {
+	return len(bytes.TrimLeft(line, " \t\r")) == 0
+}
+
+// textBlockIndent returns the width of the indentation common to the
+// lines of a text block. Blank lines do not count, except for the last,
+// which holds the closing """.
+func textBlockIndent(lines [][]byte) int {
+	common := -1
+	for i, line := range lines {
+		if i < len(lines)-1 && isBlank(line) {
+			continue
+		}
+		n := len(line) - len(bytes.TrimLeft(line, " \t"))
+		if common < 0 || n < common {
+			common = n
+		}
+	}
+	return common
+}
+
+// textBlockValue returns the text of a text block with its indentation
+// removed but escape sequences intact.
+func textBlockValue(s []byte) []byte {
+	lines := textBlockLines(s)
+	common := textBlockIndent(lines)
+	last := len(lines) - 1
+	closingAlone := isBlank(lines[last])
+	if closingAlone {
+		lines = lines[:last]
+	}
+	var b []byte
+	for i, line := range lines {
+		if i > 0 {
+			b = append(b, '\n')
+		}
+		line = bytes.TrimRight(line, " \t\r")
+		if len(line) > common {
+			b = append(b, line[common:]...)
+		}
+	}
+	if closingAlone && len(lines) > 0 {
+		b = append(b, '\n')
+	}
+	return b
+}
+
+// reindentTextBlock returns a text block with its common indentation
+// replaced by indent, which leaves its value unchanged.
+func reindentTextBlock(s, indent []byte) []byte {
+	lines := textBlockLines(s)
+	common := textBlockIndent(lines)
+	b := make([]byte, 0, len(s)+len(lines)*len(indent))
+	b = append(b, tripleQuote...)
+	for i, line := range lines {
+		b = append(b, '\n')
+		if i < len(lines)-1 {
+			line = bytes.TrimRight(line, " \t\r")
+			if len(line) == 0 {
+				continue
+			}
+		}
+		b = append(b, indent...)
+		b = append(b, line[common:]...)
+	}
+	return append(b, tripleQuote...)
+}
@@ -0,0 +1,133 @@
+package ast
+
+import (
+	"testing"
+)
+
+func TestTextBlockValue(t *testing.T) {
+	for _, tc := range []struct {
+		in, expected string
+	}{
+		{"\"\"\"\n  a\n    b\n  \"\"\"", "a\n  b\n"},
+		{"\"\"\"\n  a\n    b\"\"\"", "a\n  b"},
+		{"\"\"\"\n    a\n  \"\"\"", "  a\n"},
+		{"\"\"\"\n  a  \n\n  b\t\n  \"\"\"", "a\n\nb\n"},
+		{"\"\"\"  \r\n  a\r\n  b\r\n  \"\"\"", "a\nb\n"},
+		{"\"\"\"\n  \\\"\"\" \\t \\u00e9\\n\n  \"\"\"", "\"\"\" \t é\n\n"},
+		{"\"\"\"\n\t\ta\"b\n\t\t\"\"\"", "a\"b\n"},
+		{"\"\"\"\n\"\"\"", ""},
+	} {
+		out, ok := unquote([]byte(tc.in))
+		if !ok {
+			t.Errorf("input %q: failed to unquote", tc.in)
+		} else if out != tc.expected {
+			t.Errorf("input %q: expected %q, got %q", tc.in, tc.expected, out)
+		}
+	}
+}
+
+const textBlockSample = `{
+  "query": """
+      SELECT *
+        FROM t
+      WHERE name = "x" -- /* not a comment */
+      """,
+  "cert": [
+    """
+    -----BEGIN CERTIFICATE-----
+    MIIB
+    -----END CERTIFICATE-----""",
+  ],
+}
+`
+
+func TestTextBlockStrip(t *testing.T) {
+	expected := `{"query":"SELECT *\n  FROM t\nWHERE name = \"x\" -- /* not a comment */\n","cert":["-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----"]}`
+	out, err := Strip([]byte(textBlockSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	var v struct {
+		Query string
+		Cert  []string
+	}
+	if err := Unmarshal([]byte(textBlockSample), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Query != "SELECT *\n  FROM t\nWHERE name = \"x\" -- /* not a comment */\n" {
+		t.Errorf("unexpected query %q", v.Query)
+	}
+
+	for _, in := range []string{
+		`"""abc"""`,
+		"\"\"\"\nabc",
+		"\"\"\"\na\x01b\n\"\"\"",
+		"\"\"\"\n\\x\n\"\"\"",
+		// Ordinary strings still may not span lines.
+		"\"a\nb\"",
+	} {
+		if _, err := Strip([]byte(in)); err == nil {
+			t.Errorf("input %q: expected error", in)
+		}
+	}
+	if _, err := StripStrict([]byte("\"\"\"\nabc\n\"\"\"")); err == nil {
+		t.Error("expected error in strict mode")
+	}
+}
+
+func TestTextBlockFmt(t *testing.T) {
+	f, err := Parse([]byte(textBlockSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "query": """
+    SELECT *
+      FROM t
+    WHERE name = "x" -- /* not a comment */
+    """,
+  "cert": [
+    """
+      -----BEGIN CERTIFICATE-----
+      MIIB
+      -----END CERTIFICATE-----""",
+  ],
+}
+`
+	out := FmtJsonr(f)
+	if string(out) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	if out := FmtJsonr(f, OptionIndent("\t")); string(out) != "{\n\t\"query\": \"\"\"\n\t\tSELECT *\n\t\t  FROM t\n\t\tWHERE name = \"x\" -- /* not a comment */\n\t\t\"\"\",\n\t\"cert\": [\n\t\t\"\"\"\n\t\t\t-----BEGIN CERTIFICATE-----\n\t\t\tMIIB\n\t\t\t-----END CERTIFICATE-----\"\"\",\n\t],\n}\n" {
+		t.Errorf("unexpected tab indentation:\n%s", out)
+	}
+
+	// Reformatting leaves the values as they were.
+	a, _ := Strip([]byte(textBlockSample))
+	b, err := Strip(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) {
+		t.Errorf("expected %s, got %s", a, b)
+	}
+
+	expected = `{
+  "query": "SELECT *\n  FROM t\nWHERE name = \"x\" -- /* not a comment */\n",
+  "cert": ["-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----"]
+}`
+	if out := string(FmtJson(f.Root)); out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	expected = `/query = "SELECT *\n  FROM t\nWHERE name = \"x\" -- /* not a comment */\n"
+/cert/0 = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----"
+`
+	if out := FmtKeyValue(f); out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
@@ -0,0 +1,72 @@
+// jsonr-doc tool
+// Generate Markdown reference documentation from a commented JSONR file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/mattn/go-isatty"
+	"github.com/msolo/jsonr/ast"
+	"github.com/msolo/jsonr/internal/refdoc"
+)
+
+var usage = `Generate a Markdown reference for the options in a commented JSONR file.
+
+  jsonr-doc default.jsonr > CONFIG.md
+
+Each object gets a section with a table of its keys giving their type,
+default value and the description from the comments attached to them.
+Comment lines like "@deprecated Use listen instead." are annotations.
+
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	title := flag.String("title", "", "title of the document; the file name by default")
+	json5 := flag.Bool("json5", false, "accept JSON5 syntax such as unquoted keys and single-quoted strings")
+	flag.Parse()
+
+	var path string
+	switch flag.NArg() {
+	case 0:
+		if isatty.IsTerminal(os.Stdin.Fd()) {
+			os.Exit(1) // Nothing to do and probably an error.
+		}
+		path = "/dev/stdin"
+		if *title == "" {
+			*title = "Configuration"
+		}
+	case 1:
+		path = flag.Arg(0)
+		if *title == "" {
+			*title = filepath.Base(path)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	in, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var options []ast.ParseOption
+	if *json5 {
+		options = append(options, ast.OptionDialect(ast.DialectJSON5))
+	}
+	f, err := ast.ParseFile(ast.NewFileSet(), path, in, options...)
+	if err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	if err := refdoc.Markdown(os.Stdout, f, *title); err != nil {
+		log.Fatal(err)
+	}
+}
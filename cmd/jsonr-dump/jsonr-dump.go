@@ -6,11 +6,11 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 
 	"github.com/mattn/go-isatty"
 	"github.com/msolo/jsonr/ast"
+	"github.com/msolo/jsonr/internal/batch"
 )
 
 var usage = `Simple tool to dump a JSON obect as flat list of line-oriented key path and value pairs.
@@ -24,6 +24,8 @@ func main() {
 		flag.PrintDefaults()
 	}
 	useExpr := flag.Bool("use-expr", false, "Use expression notation.")
+	var batchConfig batch.Config
+	batchConfig.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 	paths := flag.Args()
 	if len(paths) == 0 {
@@ -34,29 +36,23 @@ func main() {
 		}
 	}
 
-	for _, p := range paths {
-		f, err := os.Open(p)
+	dump := func(p string) ([]byte, error) {
+		in, err := ioutil.ReadFile(p)
 		if err != nil {
-			log.Fatal(err)
-		}
-		in, err := ioutil.ReadAll(f)
-		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 
 		root, err := ast.Parse(in)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		var out string
 		if *useExpr {
-			out = ast.FmtKeyValue(root, ast.OptionKeyFormatter(ast.FmtKeyAsExpression))
-		} else {
-			out = ast.FmtKeyValue(root)
-		}
-		_, err = os.Stdout.Write([]byte(out))
-		if err != nil {
-			log.Fatal(err)
+			return []byte(ast.FmtKeyValue(root, ast.OptionKeyFormatter(ast.FmtKeyAsExpression))), nil
 		}
+		return []byte(ast.FmtKeyValue(root)), nil
+	}
+
+	if batchConfig.Run(batchConfig.Paths(paths), dump, os.Stdout, os.Stderr) > 0 {
+		os.Exit(1)
 	}
 }
@@ -7,9 +7,13 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/mattn/go-isatty"
 	"github.com/msolo/jsonr/ast"
+	"github.com/msolo/jsonr/internal/batch"
+	"github.com/msolo/jsonr/internal/safefile"
 )
 
 var usage = `Simple tool to canonically format JSONR.
@@ -17,58 +21,182 @@ var usage = `Simple tool to canonically format JSONR.
   jsonr-fmt < something.jsonr > formatted.jsonr
   jsonr-fmt something.jsonr
   jsonr-fmt -w something.jsonr
+  jsonr-fmt -w -exclude 'testdata' ./configs/...
+  jsonr-fmt -w -backup config.jsonr
+  jsonr-fmt -json5 -normalize settings.json5 > settings.jsonr
+  jsonr-fmt -keys-first name,version -key-order natural package.jsonr
+  jsonr-fmt -w -keys-like defaults.jsonr ./configs/...
+  jsonr-fmt -sort-array /allow -sort-array '/deps,by=name,unique' config.jsonr
+
+Arrays are also sorted when marked by a comment before them, like
+  // jsonr-fmt:sort by=name unique
 
 `
 
+// sortArrayFlags collects -sort-array flags.
+type sortArrayFlags []ast.Option
+
+func (s *sortArrayFlags) String() string {
+	return ""
+}
+
+// Set parses PATH[,by=FIELD][,unique].
+func (s *sortArrayFlags) Set(value string) error {
+	parts := strings.Split(value, ",")
+	sort, err := ast.ParseArraySort(strings.Join(parts[1:], " "))
+	if err != nil {
+		return err
+	}
+	*s = append(*s, ast.OptionSortArray(parts[0], sort))
+	return nil
+}
+
 func main() {
 	flag.Usage = func() {
 		fmt.Fprint(flag.CommandLine.Output(), usage)
 		flag.PrintDefaults()
 	}
 	overwrite := flag.Bool("w", false, "write result to source file instead of stdout")
-	sortKeys := flag.Bool("s", false, "sort object keys")
+	backup := flag.Bool("backup", false, "with -w, save the original of each changed file with a .bak suffix")
+	sortKeys := flag.Bool("s", false, "sort object keys; the same as -key-order lexical")
+	keyOrder := flag.String("key-order", "", "sort object keys: \"lexical\", \"case-insensitive\" or \"natural\" to sort numbers within keys by value")
+	keysFirst := flag.String("keys-first", "", "comma-separated keys to put first in every object, in this order")
+	keysLike := flag.String("keys-like", "", "order keys as in the object at the same path in this reference file")
+	indent := flag.String("indent", "2", "indent with this many spaces, \"tab\", or \"auto\" to preserve the indentation of each file")
+	json5 := flag.Bool("json5", false, "accept JSON5 syntax such as unquoted keys and single-quoted strings")
+	normalize := flag.Bool("normalize", false, "rewrite JSON5 syntax as JSONR")
+	var sortArrays sortArrayFlags
+	flag.Var(&sortArrays, "sort-array", "sort the array at this key path, where * matches any key or index; append \",by=FIELD\" to sort objects by a field and \",unique\" to remove duplicates")
+	lineEnding := flag.String("line-ending", "auto", "end lines with \"lf\" or \"crlf\", or \"auto\" to preserve the dominant line ending of each file")
+	width := flag.Int("width", 80, "print short arrays and objects on one line if they fit in this many columns, 0 to always expand")
+	var batchConfig batch.Config
+	batchConfig.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
+	var indentOpt ast.Option
+	switch *indent {
+	case "auto":
+	case "tab":
+		indentOpt = ast.OptionIndent("\t")
+	default:
+		n, err := strconv.Atoi(*indent)
+		if err != nil || n < 0 {
+			log.Fatalf("invalid -indent %q: must be a number of spaces, \"tab\" or \"auto\"", *indent)
+		}
+		indentOpt = ast.OptionIndentWidth(n)
+	}
+
+	var parseOpts []ast.ParseOption
+	if *json5 {
+		parseOpts = append(parseOpts, ast.OptionDialect(ast.DialectJSON5))
+	}
+
+	var order ast.KeyOrder
+	if *sortKeys && *keyOrder == "" {
+		*keyOrder = "lexical"
+	}
+	switch *keyOrder {
+	case "":
+	case "lexical":
+		order = ast.KeyOrderLexical
+	case "case-insensitive":
+		order = ast.KeyOrderCaseInsensitive
+	case "natural":
+		order = ast.KeyOrderNatural
+	default:
+		log.Fatalf("invalid -key-order %q: must be \"lexical\", \"case-insensitive\" or \"natural\"", *keyOrder)
+	}
+	if *keysFirst != "" {
+		order = ast.KeyOrderPriority(order, strings.Split(*keysFirst, ",")...)
+	}
+	if *keysLike != "" {
+		// Read the reference the same way as the files to format.
+		raw, err := ioutil.ReadFile(*keysLike)
+		if err != nil {
+			log.Fatal(err)
+		}
+		in, err := ast.DecodeSource(raw)
+		if err != nil {
+			log.Fatalf("%s: %v", *keysLike, err)
+		}
+		ref, err := ast.Parse(in, parseOpts...)
+		if err != nil {
+			log.Fatalf("%s: %v", *keysLike, err)
+		}
+		order = ast.KeyOrderLike(ref, order)
+	}
+
+	var lineEndingOpt ast.Option
+	switch *lineEnding {
+	case "auto":
+	case "lf":
+		lineEndingOpt = ast.OptionLineEnding("\n")
+	case "crlf":
+		lineEndingOpt = ast.OptionLineEnding("\r\n")
+	default:
+		log.Fatalf("invalid -line-ending %q: must be \"lf\", \"crlf\" or \"auto\"", *lineEnding)
+	}
+
 	paths := flag.Args()
 	if len(paths) == 0 {
 		if isatty.IsTerminal(os.Stdin.Fd()) {
 			os.Exit(1) // Nothing to do and probably an error.
 		} else {
+			if *overwrite {
+				log.Fatal("cannot use -w with standard input")
+			}
 			paths = []string{"/dev/stdin"}
 		}
 	}
 
-	for _, p := range paths {
-		f, err := os.Open(p)
+	format := func(p string) ([]byte, error) {
+		raw, err := ioutil.ReadFile(p)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		in, err := ioutil.ReadAll(f)
+		// Write the file back in the encoding it was read in.
+		enc, bom := ast.DetectEncoding(raw)
+		in, err := ast.DecodeSource(raw)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 
-		_ = f.Close()
-
-		root, err := ast.Parse(in)
+		root, err := ast.Parse(in, parseOpts...)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		opts := []ast.Option{}
-		if *sortKeys {
-			opts = append(opts, ast.OptionSortKeys)
+		opts := []ast.Option{ast.OptionLineWidth(*width)}
+		if *normalize {
+			opts = append(opts, ast.OptionNormalize)
 		}
-		out := ast.FmtJsonr(root, opts...)
-		outFile := os.Stdout
+		if indentOpt != nil {
+			opts = append(opts, indentOpt)
+		} else {
+			opts = append(opts, ast.OptionDetectIndent(in))
+		}
+		if lineEndingOpt != nil {
+			opts = append(opts, lineEndingOpt)
+		} else {
+			opts = append(opts, ast.OptionDetectLineEnding(in))
+		}
+		if order != nil {
+			opts = append(opts, ast.OptionKeyOrder(order))
+		}
+		opts = append(opts, sortArrays...)
+		opts = append(opts, ast.OptionSortDirectives)
+		out := ast.EncodeSource(ast.FmtJsonr(root, opts...), enc, bom)
 		if *overwrite {
-			outFile, err = os.OpenFile(p, os.O_TRUNC|os.O_WRONLY, 0664)
-			if err != nil {
-				log.Fatal(err)
+			suffix := ""
+			if *backup {
+				suffix = ".bak"
 			}
+			_, err := safefile.WriteFile(p, out, suffix)
+			return nil, err
 		}
-		_, err = outFile.Write([]byte(out))
-		if err != nil {
-			log.Fatal(err)
-		}
+		return out, nil
+	}
+
+	if batchConfig.Run(batchConfig.Paths(paths), format, os.Stdout, os.Stderr) > 0 {
+		os.Exit(1)
 	}
 }
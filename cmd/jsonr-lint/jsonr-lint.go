@@ -0,0 +1,121 @@
+// jsonr-lint tool
+// Check JSONR files for likely mistakes and style problems.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/mattn/go-isatty"
+	"github.com/msolo/jsonr/internal/batch"
+	"github.com/msolo/jsonr/lint"
+)
+
+var usage = `Check JSONR files for likely mistakes and style problems.
+
+  jsonr-lint something.jsonr
+  jsonr-lint -format json ./configs/...
+
+Rules are configured by the nearest .jsonr-lint.jsonr file in the
+directory of each file or its parents, or by -config. Standard input
+uses the one for the current directory. Disable rules within a file
+with a comment like // jsonr-lint:disable=todo,empty-container
+
+`
+
+// jsonDiagnostic is the -format json representation of a diagnostic.
+type jsonDiagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	configPath := flag.String("config", "", "read rules from this file instead of the nearest "+lint.ConfigFile)
+	format := flag.String("format", "text", "write diagnostics as \"text\", or as \"json\" with one object per line")
+	listRules := flag.Bool("rules", false, "list the rules and exit")
+	var batchConfig batch.Config
+	batchConfig.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	if *listRules {
+		for _, r := range lint.Rules {
+			fmt.Printf("%-16s %s\n", r.Name, r.Doc)
+		}
+		return
+	}
+	if *format != "text" && *format != "json" {
+		log.Fatalf("invalid -format %q: must be \"text\" or \"json\"", *format)
+	}
+
+	var config *lint.Config
+	if *configPath != "" {
+		var err error
+		if config, err = lint.LoadConfig(*configPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	finder := &lint.ConfigFinder{}
+
+	paths := flag.Args()
+	if len(paths) == 0 {
+		if isatty.IsTerminal(os.Stdin.Fd()) {
+			os.Exit(1) // Nothing to do and probably an error.
+		} else {
+			paths = []string{"/dev/stdin"}
+		}
+	}
+
+	var found int32
+	check := func(p string) ([]byte, error) {
+		in, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		c := config
+		if c == nil {
+			dir := filepath.Dir(p)
+			if p == "/dev/stdin" {
+				dir = "."
+			}
+			if c, err = finder.Config(dir); err != nil {
+				return nil, err
+			}
+		}
+		diags := c.File(p, in)
+		if len(diags) > 0 {
+			atomic.AddInt32(&found, 1)
+		}
+		out := &bytes.Buffer{}
+		for _, d := range diags {
+			if *format == "json" {
+				data, err := json.Marshal(jsonDiagnostic{d.Pos.Filename, d.Pos.Line, d.Pos.Column, d.Rule, d.Msg})
+				if err != nil {
+					return nil, err
+				}
+				out.Write(data)
+				out.WriteByte('\n')
+			} else {
+				fmt.Fprintln(out, d)
+			}
+		}
+		return out.Bytes(), nil
+	}
+
+	if batchConfig.Run(batchConfig.Paths(paths), check, os.Stdout, os.Stderr) > 0 || found > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,116 @@
+// jsonr-merge-driver tool
+// Merge JSONR files structurally as a Git merge driver.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+
+	"github.com/msolo/jsonr/ast"
+	"github.com/msolo/jsonr/internal/safefile"
+)
+
+var usage = `Merge the changes to a JSONR file key by key, as a Git merge driver.
+
+  jsonr-merge-driver BASE OURS THEIRS
+
+The result is written to OURS. Conflict markers surround only the
+fields and elements changed differently on each side, and the command
+exits with status 1 if there are any. Files that cannot be parsed are
+merged line by line with git merge-file instead.
+
+To use it, add to .git/config or ~/.gitconfig:
+
+  [merge "jsonr"]
+  	name = JSONR structural merge
+  	driver = jsonr-merge-driver %O %A %B
+
+and to .gitattributes:
+
+  *.jsonr merge=jsonr
+
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	oursLabel := flag.String("ours-label", "ours", "label for our side of conflicts")
+	theirsLabel := flag.String("theirs-label", "theirs", "label for their side of conflicts")
+	json5 := flag.Bool("json5", false, "accept JSON5 syntax such as unquoted keys and single-quoted strings")
+	flag.Parse()
+	if flag.NArg() != 3 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	basePath, oursPath, theirsPath := flag.Arg(0), flag.Arg(1), flag.Arg(2)
+
+	var options []ast.ParseOption
+	if *json5 {
+		options = append(options, ast.OptionDialect(ast.DialectJSON5))
+	}
+	fset := ast.NewFileSet()
+	var files [3]*ast.File
+	var sources [3][]byte
+	for i, path := range []string{basePath, oursPath, theirsPath} {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sources[i] = raw
+		in, err := ast.DecodeSource(raw)
+		if err == nil {
+			files[i], err = ast.ParseFile(fset, path, in, options...)
+		}
+		if err != nil {
+			log.Printf("%s: %v; merging line by line", path, err)
+			os.Exit(mergeLines(basePath, oursPath, theirsPath, *oursLabel, *theirsLabel))
+		}
+	}
+
+	merged, conflicts := ast.Merge3(files[0], files[1], files[2])
+	for _, c := range conflicts {
+		log.Printf("conflict at %s", c.Path)
+	}
+	var out []byte
+	switch {
+	case len(conflicts) == 0 && ast.Equal(merged, files[1], ast.OptionCompareComments, ast.OptionCompareKeyOrder):
+		return // nothing to change
+	case len(conflicts) == 0 && ast.Equal(merged, files[2], ast.OptionCompareComments, ast.OptionCompareKeyOrder):
+		out = sources[2]
+	default:
+		// Format the result like our side, in its encoding.
+		enc, bom := ast.DetectEncoding(sources[1])
+		in, _ := ast.DecodeSource(sources[1])
+		out = ast.EncodeSource(ast.FmtJsonr(merged,
+			ast.OptionDetectIndent(in),
+			ast.OptionDetectLineEnding(in),
+			ast.OptionConflictMarkers(conflicts, *oursLabel, *theirsLabel)), enc, bom)
+	}
+	if _, err := safefile.WriteFile(oursPath, out, ""); err != nil {
+		log.Fatal(err)
+	}
+	if len(conflicts) > 0 {
+		os.Exit(1)
+	}
+}
+
+// mergeLines merges the files with git merge-file and returns the exit
+// status for the driver.
+func mergeLines(basePath, oursPath, theirsPath, oursLabel, theirsLabel string) int {
+	cmd := exec.Command("git", "merge-file", "-L", oursLabel, "-L", "base", "-L", theirsLabel,
+		oursPath, basePath, theirsPath)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			log.Print(err)
+		}
+		return 1
+	}
+	return 0
+}
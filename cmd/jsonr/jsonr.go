@@ -4,16 +4,22 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 
 	"github.com/mattn/go-isatty"
 	"github.com/msolo/jsonr/ast"
+	"github.com/msolo/jsonr/internal/batch"
 )
 
 var usage = `Simple tool to convert from JSONR to plain-old JSON.
 
   jsonr < something.jsonr > something.json
+  jsonr a.jsonr b.jsonr ./more/...
+  jsonr -lines < records.jsonrl > records.ndjson
+  jsonr -json5 < settings.json5 > settings.json
+  jsonr -canonical config.jsonr | sha256sum
+
+Input may contain any number of values, such as JSONR Lines.
 `
 
 func main() {
@@ -21,6 +27,11 @@ func main() {
 		fmt.Fprint(flag.CommandLine.Output(), usage)
 		flag.PrintDefaults()
 	}
+	lines := flag.Bool("lines", false, "write each value on a single line (NDJSON)")
+	canonical := flag.Bool("canonical", false, "write each value in the canonical form of RFC 8785 for hashing and signing")
+	json5 := flag.Bool("json5", false, "accept JSON5 syntax such as unquoted keys and single-quoted strings")
+	var batchConfig batch.Config
+	batchConfig.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 	paths := flag.Args()
 	if len(paths) == 0 {
@@ -31,23 +42,54 @@ func main() {
 		}
 	}
 
-	for _, p := range paths {
-		f, err := os.Open(p)
-		if err != nil {
-			log.Fatal(err)
-		}
-		in, err := ioutil.ReadAll(f)
-		if err != nil {
-			log.Fatal(err)
-		}
-		root, err := ast.Parse(in)
+	c := &converter{lines: *lines, canonical: *canonical, json5: *json5}
+	convert := func(p string) ([]byte, error) {
+		in, err := ioutil.ReadFile(p)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		out := ast.FmtJson(root)
-		_, err = os.Stdout.Write([]byte(out))
-		if err != nil {
-			log.Fatal(err)
+		return c.convert(in)
+	}
+
+	if batchConfig.Run(batchConfig.Paths(paths), convert, os.Stdout, os.Stderr) > 0 {
+		os.Exit(1)
+	}
+}
+
+// converter holds the flags that control conversion.
+type converter struct {
+	lines     bool
+	canonical bool
+	json5     bool
+}
+
+// convert returns each value in the input as JSON, followed by a
+// newline.
+func (c *converter) convert(in []byte) ([]byte, error) {
+	var parseOpts []ast.ParseOption
+	if c.json5 {
+		parseOpts = append(parseOpts, ast.OptionDialect(ast.DialectJSON5))
+	}
+	files, err := ast.ParseAll(in, parseOpts...)
+	if err != nil {
+		return nil, err
+	}
+	var opts []ast.Option
+	if c.lines {
+		opts = append(opts, ast.OptionUnlimitedLineWidth)
+	}
+	var out []byte
+	for _, f := range files {
+		if c.canonical {
+			b, err := ast.FmtCanonical(f.Root)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, b...)
+		} else {
+			out = append(out, ast.FmtJson(f.Root, opts...)...)
 		}
+		out = append(out, '\n')
 	}
+	return out, nil
 }
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestConvertLines(t *testing.T) {
+	var in, expected bytes.Buffer
+	for i := 0; i < 100; i++ {
+		name := strings.Repeat("x", i)
+		fmt.Fprintf(&in, "// record %d\n{\"id\": %d, \"name\": \"%s\", \"tags\": [1, 2,],}\n", i, i, name)
+		fmt.Fprintf(&expected, "{\"id\": %d, \"name\": \"%s\", \"tags\": [1, 2]}\n", i, name)
+	}
+	c := &converter{lines: true}
+	out, err := c.convert(in.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(out); out != expected.String() {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected.String(), out)
+	}
+}
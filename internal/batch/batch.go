@@ -0,0 +1,154 @@
+// Package batch runs the jsonr commands over many files concurrently
+// while keeping their output in argument order.
+package batch
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Config controls how arguments are expanded into files and how many
+// files are processed at once.
+type Config struct {
+	Jobs    int
+	Include []string // globs for files found in directories
+	Exclude []string // globs for files and directories to skip
+}
+
+// RegisterFlags adds -j, -include and -exclude to fs. Globs are
+// comma-separated lists.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	c.Jobs = runtime.GOMAXPROCS(0)
+	c.Include = []string{"*.json", "*.jsonr"}
+	fs.IntVar(&c.Jobs, "j", c.Jobs, "process this many files concurrently")
+	fs.Var((*globList)(&c.Include), "include", "comma-separated globs of files to process in directories")
+	fs.Var((*globList)(&c.Exclude), "exclude", "comma-separated globs of files and directories to skip")
+}
+
+type globList []string
+
+func (g *globList) String() string {
+	return strings.Join(*g, ",")
+}
+
+func (g *globList) Set(s string) error {
+	*g = nil
+	for _, glob := range strings.Split(s, ",") {
+		if glob == "" {
+			continue
+		}
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %v", glob, err)
+		}
+		*g = append(*g, glob)
+	}
+	return nil
+}
+
+// Paths expands args into the files to process. A directory, or a path
+// ending in "/..." as in "./configs/...", is searched recursively for
+// files matching the include globs. Other arguments are used as is,
+// unless they match an exclude glob; if one cannot be read, the error
+// is reported when it is processed.
+func (c *Config) Paths(args []string) []string {
+	var paths []string
+	for _, arg := range args {
+		root := arg
+		if strings.HasSuffix(arg, "/...") {
+			root = strings.TrimSuffix(arg, "...")
+		}
+		if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+			if !match(c.Exclude, arg, arg) {
+				paths = append(paths, arg)
+			}
+			continue
+		}
+		_ = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+			rel, _ := filepath.Rel(root, path)
+			if err != nil {
+				// Let processing report the error.
+				paths = append(paths, path)
+				return nil
+			}
+			if path != root && match(c.Exclude, path, rel) {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !fi.IsDir() && match(c.Include, path, rel) {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+	}
+	return paths
+}
+
+// match reports whether a glob matches the base name of path or its
+// path relative to the directory being searched.
+func match(globs []string, path, rel string) bool {
+	base := filepath.Base(path)
+	for _, glob := range globs {
+		if ok, _ := filepath.Match(glob, base); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(glob, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type result struct {
+	out []byte
+	err error
+}
+
+// Run calls fn for each path using up to c.Jobs goroutines and writes
+// the output of each call to stdout in the order of paths. Errors are
+// written to stderr, prefixed with the path, and do not stop the other
+// files. Run returns the number of files that failed.
+func (c *Config) Run(paths []string, fn func(path string) ([]byte, error), stdout, stderr io.Writer) int {
+	jobs := c.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	results := make([]chan result, len(paths))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	work := make(chan int)
+	go func() {
+		for i := range paths {
+			work <- i
+		}
+		close(work)
+	}()
+	for w := 0; w < jobs; w++ {
+		go func() {
+			for i := range work {
+				out, err := fn(paths[i])
+				results[i] <- result{out, err}
+			}
+		}()
+	}
+
+	failed := 0
+	for i, ch := range results {
+		r := <-ch
+		if r.err == nil {
+			_, r.err = stdout.Write(r.out)
+		}
+		if r.err != nil {
+			failed++
+			fmt.Fprintf(stderr, "%s: %v\n", paths[i], r.err)
+		}
+	}
+	return failed
+}
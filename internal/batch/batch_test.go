@@ -0,0 +1,79 @@
+package batch
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	var paths []string
+	for i := 0; i < 20; i++ {
+		paths = append(paths, fmt.Sprint(i))
+	}
+	fn := func(p string) ([]byte, error) {
+		var i int
+		fmt.Sscan(p, &i)
+		// Finish later paths first.
+		time.Sleep(time.Duration(20-i) * time.Millisecond)
+		if i%5 == 0 {
+			return nil, fmt.Errorf("bad")
+		}
+		return []byte(p + "\n"), nil
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	c := &Config{Jobs: 8}
+	if failed := c.Run(paths, fn, stdout, stderr); failed != 4 {
+		t.Errorf("expected 4 failures, got %d", failed)
+	}
+	expected := ""
+	for i := 0; i < 20; i++ {
+		if i%5 != 0 {
+			expected += fmt.Sprintf("%d\n", i)
+		}
+	}
+	if stdout.String() != expected {
+		t.Errorf("output out of order:\n%s", stdout)
+	}
+	if expected := "0: bad\n5: bad\n10: bad\n15: bad\n"; stderr.String() != expected {
+		t.Errorf("expected errors:\n%s\ngot:\n%s", expected, stderr)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{
+		"a.jsonr", "b.json", "notes.txt", "sub/c.jsonr", "sub/d.jsonr", "testdata/e.json",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &Config{Include: []string{"*.json", "*.jsonr"}, Exclude: []string{"testdata", "sub/d.jsonr"}}
+	got := c.Paths([]string{dir + "/...", filepath.Join(dir, "notes.txt"), "missing"})
+	expected := []string{
+		filepath.Join(dir, "a.jsonr"),
+		filepath.Join(dir, "b.json"),
+		filepath.Join(dir, "sub/c.jsonr"),
+		filepath.Join(dir, "notes.txt"),
+		"missing",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,180 @@
+// Package refdoc renders the comments of a JSONR configuration file as
+// a Markdown reference for its options.
+package refdoc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/msolo/jsonr/ast"
+)
+
+// The longest default value written in full. Longer values are
+// described by type only.
+const maxDefaultWidth = 60
+
+// Markdown writes a reference for the configuration in f to w. Each
+// object gets a section with a table of its keys giving their type,
+// default value and description. The comments at the start of the file
+// introduce the document under the heading title.
+//
+// A line in a comment starting with @ is an annotation, such as
+// "@deprecated Use listen instead." or "@since 1.2". Deprecated keys are
+// struck through.
+func Markdown(w io.Writer, f *ast.File, title string) error {
+	g := &generator{docs: ast.AllDocs(f)}
+	fmt.Fprintf(&g.buf, "# %s\n", title)
+	if doc, ok := g.docs["/"]; ok {
+		fmt.Fprintf(&g.buf, "\n%s\n", doc.Doc)
+	}
+	g.walk(nil, f.Root)
+	_, err := w.Write(g.buf.Bytes())
+	return err
+}
+
+type generator struct {
+	docs map[string]ast.Doc
+	buf  bytes.Buffer
+}
+
+// walk writes a section for each object within v, which is at keyPath.
+func (g *generator) walk(keyPath []ast.KeyStep, v ast.Value) {
+	switch tv := v.(type) {
+	case *ast.Object:
+		g.section(keyPath, tv)
+		for _, fl := range tv.Fields {
+			g.walk(append(keyPath, ast.ByName(fl.Key.Name)), fl.Value)
+		}
+	case *ast.Array:
+		for i, e := range tv.Elements {
+			g.walk(append(keyPath, ast.ByIdx(i)), e.Value)
+		}
+	}
+}
+
+func (g *generator) section(keyPath []ast.KeyStep, o *ast.Object) {
+	if len(o.Fields) == 0 {
+		return // shown as {} in the table of its parent
+	}
+	path := ast.FmtKeyAsPath(keyPath)
+	if len(keyPath) > 0 {
+		fmt.Fprintf(&g.buf, "\n## %s\n", codeSpan(path))
+		if desc, _ := describe(g.docs[path]); desc != "" {
+			fmt.Fprintf(&g.buf, "\n%s\n", desc)
+		}
+	}
+
+	g.buf.WriteString("\n| Key | Type | Default | Description |\n|---|---|---|---|\n")
+	for i, fl := range o.Fields {
+		if o.Get(fl.Key.Name) != o.Fields[i] {
+			continue // overridden by a later duplicate
+		}
+		fieldPath := ast.FmtKeyAsPath(append(keyPath, ast.ByName(fl.Key.Name)))
+		desc, deprecated := describe(g.docs[fieldPath])
+		key := codeSpan(fl.Key.Name)
+		if deprecated {
+			key = "~~" + key + "~~"
+		}
+		fmt.Fprintf(&g.buf, "| %s | %s | %s | %s |\n",
+			key, typeName(fl.Value), defaultValue(fieldPath, fl.Value), tableCell(desc))
+	}
+}
+
+// An annotation is a line of a comment like "@name text".
+type annotation struct {
+	name, text string
+}
+
+func (a annotation) String() string {
+	label := strings.ToUpper(a.name[:1]) + a.name[1:]
+	if a.text == "" {
+		return "**" + label + ".**"
+	}
+	return "**" + label + ":** " + a.text
+}
+
+// describe returns the Markdown for documentation with its annotations
+// first, and whether it marks the value as deprecated.
+func describe(doc ast.Doc) (string, bool) {
+	desc, notes := parseDoc(doc.String())
+	var paras []string
+	deprecated := false
+	for _, n := range notes {
+		deprecated = deprecated || n.name == "deprecated"
+		paras = append(paras, n.String())
+	}
+	if desc != "" {
+		paras = append(paras, desc)
+	}
+	return strings.Join(paras, "\n\n"), deprecated
+}
+
+// parseDoc splits documentation into its description and annotations.
+func parseDoc(doc string) (string, []annotation) {
+	var desc []string
+	var notes []annotation
+	for _, line := range strings.Split(doc, "\n") {
+		if !strings.HasPrefix(line, "@") || len(line) == 1 {
+			desc = append(desc, line)
+			continue
+		}
+		fields := strings.SplitN(line[1:], " ", 2)
+		n := annotation{name: fields[0]}
+		if len(fields) > 1 {
+			n.text = strings.TrimSpace(fields[1])
+		}
+		notes = append(notes, n)
+	}
+	return strings.TrimSpace(strings.Join(desc, "\n")), notes
+}
+
+func typeName(v ast.Value) string {
+	switch tv := v.(type) {
+	case *ast.Object:
+		return "object"
+	case *ast.Array:
+		return "array"
+	case *ast.Literal:
+		switch tv.Type {
+		case ast.LiteralString:
+			return "string"
+		case ast.LiteralNumber:
+			return "number"
+		case ast.LiteralTrue, ast.LiteralFalse:
+			return "boolean"
+		case ast.LiteralNull:
+			return "null"
+		}
+	}
+	return ""
+}
+
+// defaultValue returns the cell for the value of the field at path.
+func defaultValue(path string, v ast.Value) string {
+	if o, ok := v.(*ast.Object); ok && len(o.Fields) > 0 {
+		return "see " + codeSpan(path)
+	}
+	b := ast.FmtJson(v, ast.OptionLineWidth(maxDefaultWidth+1))
+	if len(b) > maxDefaultWidth || bytes.IndexByte(b, '\n') >= 0 {
+		return ""
+	}
+	return tableCell(codeSpan(string(b)))
+}
+
+// codeSpan returns s as inline code.
+func codeSpan(s string) string {
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+// tableCell escapes text for a table cell, where a newline would end
+// the row.
+func tableCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	s = strings.ReplaceAll(s, "\n\n", "<br><br>")
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,111 @@
+package refdoc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/msolo/jsonr/ast"
+)
+
+const sample = `/*
+ * Settings for the frontend server.
+ *
+ * Copy this file and change what you need.
+ */
+{
+  // Address to listen on.
+  "listen": ":8080", // host:port
+  // @deprecated Use listen instead.
+  "port": 8080,
+  "debug": false,
+  "port": 8081,
+  // Backends, in order of preference.
+  "backends": [
+    {"host": "a.example.com"},
+    // Used when the first is down.
+    {"host": "b.example.com", "weight": 1},
+  ],
+  /* TLS settings. Leave out to serve plain HTTP. */
+  "tls": {
+    // PEM certificate | chain.
+    // @since 1.2
+    "cert": """
+      -----BEGIN CERTIFICATE-----
+      MIIB
+      -----END CERTIFICATE-----
+      """,
+    "key": null,
+    "a` + "`" + `b": "01234567890123456789012345678901234567890123456789012345678901234567890",
+  },
+  "limits": {},
+}
+`
+
+const expected = "# Frontend\n" + `
+Settings for the frontend server.
+
+Copy this file and change what you need.
+
+| Key | Type | Default | Description |
+|---|---|---|---|
+| ` + "`listen`" + ` | string | ` + "`\":8080\"`" + ` | Address to listen on. host:port |
+| ` + "`debug`" + ` | boolean | ` + "`false`" + ` |  |
+| ` + "`port`" + ` | number | ` + "`8081`" + ` |  |
+| ` + "`backends`" + ` | array |  | Backends, in order of preference. |
+| ` + "`tls`" + ` | object | see ` + "`/tls`" + ` | TLS settings. Leave out to serve plain HTTP. |
+| ` + "`limits`" + ` | object | ` + "`{}`" + ` |  |
+
+## ` + "`/backends/0`" + `
+
+| Key | Type | Default | Description |
+|---|---|---|---|
+| ` + "`host`" + ` | string | ` + "`\"a.example.com\"`" + ` |  |
+
+## ` + "`/backends/1`" + `
+
+Used when the first is down.
+
+| Key | Type | Default | Description |
+|---|---|---|---|
+| ` + "`host`" + ` | string | ` + "`\"b.example.com\"`" + ` |  |
+| ` + "`weight`" + ` | number | ` + "`1`" + ` |  |
+
+## ` + "`/tls`" + `
+
+TLS settings. Leave out to serve plain HTTP.
+
+| Key | Type | Default | Description |
+|---|---|---|---|
+| ` + "`cert`" + ` | string |  | **Since:** 1.2<br><br>PEM certificate \| chain. |
+| ` + "`key`" + ` | null | ` + "`null`" + ` |  |
+| ` + "`` a`b ``" + ` | string |  |  |
+`
+
+func TestMarkdown(t *testing.T) {
+	f, err := ast.Parse([]byte(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	if err := Markdown(out, f, "Frontend"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	f, err := ast.Parse([]byte("{\n  // @deprecated\n  // Use listen.\n  \"port\": 1,\n}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	if err := Markdown(out, f, "T"); err != nil {
+		t.Fatal(err)
+	}
+	row := "| ~~`port`~~ | number | `1` | **Deprecated.**<br><br>Use listen. |\n"
+	if !bytes.HasSuffix(out.Bytes(), []byte(row)) {
+		t.Errorf("expected row %q in:\n%s", row, out)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package safefile
+
+import (
+	"os"
+	"syscall"
+)
+
+// chown gives f the owner and group of fi, if permitted. Only root can
+// give a file away, but an owner may change its group to another they
+// belong to.
+func chown(f *os.File, fi os.FileInfo) {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		if f.Chown(int(st.Uid), int(st.Gid)) != nil {
+			f.Chown(-1, int(st.Gid))
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Package safefile replaces files in place for the jsonr commands
+// without leaving them truncated if interrupted.
+package safefile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile replaces the contents of the existing file at path with
+// data and reports whether it changed. A file that already holds data
+// is left alone, so its modification time does not change.
+//
+// The data is written to a temporary file in the same directory and
+// renamed over the original, so a crash leaves either the old or the
+// new contents. The new file keeps the mode of the original, and its
+// owner and group where permitted. If path is a symlink, the file it
+// points to is replaced. If backupSuffix is not empty, the original
+// contents are first saved the same way to path+backupSuffix.
+func WriteFile(path string, data []byte, backupSuffix string) (bool, error) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	old, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(old, data) {
+		return false, nil
+	}
+	if backupSuffix != "" {
+		if err := replace(path+backupSuffix, old, fi); err != nil {
+			return false, err
+		}
+	}
+	if err := replace(path, data, fi); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// replace atomically writes data to path with the mode and ownership
+// of fi.
+func replace(path string, data []byte, fi os.FileInfo) (err error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	// Changing the owner may clear the setuid and setgid bits, so do it
+	// before setting the mode.
+	chown(tmp, fi)
+	if err = tmp.Chmod(fi.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,88 @@
+package safefile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safefile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a.jsonr")
+	if err := ioutil.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged content is not written.
+	if changed, err := WriteFile(path, []byte("old"), ".bak"); err != nil || changed {
+		t.Fatalf("expected no change, got %v %v", changed, err)
+	}
+	if fi, err := os.Stat(path); err != nil || !fi.ModTime().Equal(past) {
+		t.Fatalf("expected modification time to be kept: %v", err)
+	}
+	if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+		t.Fatalf("expected no backup: %v", err)
+	}
+
+	if changed, err := WriteFile(path, []byte("new"), ".bak"); err != nil || !changed {
+		t.Fatalf("expected a change, got %v %v", changed, err)
+	}
+	checkFile := func(path, expected string) {
+		t.Helper()
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected {
+			t.Fatalf("%s: expected %q, got %q", path, expected, data)
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runtime.GOOS != "windows" && fi.Mode().Perm() != 0640 {
+			t.Fatalf("%s: expected mode 0640, got %v", path, fi.Mode())
+		}
+	}
+	checkFile(path, "new")
+	checkFile(path+".bak", "old")
+
+	// A symlink is followed rather than replaced.
+	link := filepath.Join(dir, "link.jsonr")
+	if err := os.Symlink(path, link); err != nil {
+		t.Skip(err)
+	}
+	if _, err := WriteFile(link, []byte("newer"), ""); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(path, "newer")
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("expected the symlink to be kept: %v", err)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected no temporary files to be left, got %d entries", len(entries))
+	}
+
+	if _, err := WriteFile(filepath.Join(dir, "missing.jsonr"), []byte("x"), ""); !os.IsNotExist(err) {
+		t.Fatalf("expected a missing file to be an error, got %v", err)
+	}
+}
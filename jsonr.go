@@ -6,19 +6,24 @@
 package jsonr
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"io"
 
 	"github.com/msolo/jsonr/ast"
 )
 
-// See json.Unmarshal.
+// See json.Unmarshal. The input is decoded in a single pass rather
+// than being stripped to JSON first; see ast.Unmarshal.
 func Unmarshal(data []byte, v interface{}) error {
-	js, err := ast.Strip(data)
-	if err != nil {
-		return err
-	}
-	return json.Unmarshal(js, v)
+	return ast.Unmarshal(data, v)
+}
+
+// UnmarshalStrict is like Unmarshal, but apart from comments and
+// trailing commas accepts only what RFC 8259 allows, such as strings
+// of valid UTF-8 on a single line; see ast.UnmarshalStrict.
+func UnmarshalStrict(data []byte, v interface{}) error {
+	return ast.UnmarshalStrict(data, v)
 }
 
 // FIXME(msolo) This strips a whole buffer at a time rather than
@@ -41,3 +46,19 @@ func Convert2Json(in []byte) ([]byte, error) {
 	}
 	return ast.FmtJson(tree), nil
 }
+
+// Hash returns the SHA-256 digest of the canonical form of a JSONR
+// value, so values that differ only in comments, formatting, key order,
+// string escapes or number spelling hash identically. See
+// ast.FmtCanonical.
+func Hash(data []byte) ([32]byte, error) {
+	tree, err := ast.Parse(data)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	b, err := ast.FmtCanonical(tree)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(b), nil
+}
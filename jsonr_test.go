@@ -3,6 +3,8 @@ package jsonr
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/msolo/jsonr/ast"
@@ -81,14 +83,9 @@ func BenchmarkJSONUnmarshalEmptyStruct(b *testing.B) {
 	}
 }
 
-// FIXME(msolo) Benchmark confirms that stripping comments is 2.5x
-// more expensive than simple JSON parsing. I would accept 2x given
-// the naive approach of scanning twice. The original channel approach
-// was 9x more expensive. The current overhead is likely due to UTF8
-// parsing and readable code style rather than just having a simple
-// merged loop over bytes. Of course, it is still absolutely
-// "fast-enough" for almost any application I had planned, but best to
-// know the costs.
+// Unmarshal decodes in a single pass driven by the JSONR scanner rather
+// than stripping comments into a second buffer and scanning it all
+// again with encoding/json, which cost about 2.5x plain JSON parsing.
 func BenchmarkJSONRUnmarshalEmptyStruct(b *testing.B) {
 	in := benchChunk
 	out := &struct{}{}
@@ -100,6 +97,20 @@ func BenchmarkJSONRUnmarshalEmptyStruct(b *testing.B) {
 	}
 }
 
+func BenchmarkJSONRStripUnmarshalEmptyStruct(b *testing.B) {
+	in := benchChunk
+	out := &struct{}{}
+	for i := 0; i < b.N; i++ {
+		js, err := ast.Strip(in)
+		if err != nil {
+			b.Errorf("benchmark err: %s", err)
+		}
+		if err := json.Unmarshal(js, out); err != nil {
+			b.Errorf("benchmark err: %s", err)
+		}
+	}
+}
+
 func BenchmarkJSONUnmarshalMap(b *testing.B) {
 	in := benchChunk
 	out := make(map[string]interface{})
@@ -154,6 +165,101 @@ func BenchmarkStripReader(b *testing.B) {
 	}
 }
 
+// benchLarge is a few megabytes of commented JSONR for measuring
+// throughput rather than per-call overhead.
+var benchLarge = func() []byte {
+	b := &bytes.Buffer{}
+	b.WriteString("// Large document.\n[\n")
+	for i := 0; b.Len() < 4<<20; i++ {
+		fmt.Fprintf(b, `  // Record %d.
+  {
+    "id": %d, // Trailing comment.
+    "name": "record \"%d\"",
+    "score": %d.5e-3,
+    "active": true,
+    "tags": ["a", "b", "c",],
+    /* Labels. */
+    "labels": {"env": "prod", "tier": null},
+  },
+`, i, i, i, i)
+	}
+	b.WriteString("]\n")
+	return b.Bytes()
+}()
+
+func BenchmarkStripLarge(b *testing.B) {
+	in := benchLarge
+	b.SetBytes(int64(len(in)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := ast.Strip(in)
+		if err != nil {
+			b.Errorf("benchmark err: %s", err)
+		}
+	}
+}
+
+func BenchmarkParseLarge(b *testing.B) {
+	in := benchLarge
+	b.SetBytes(int64(len(in)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := ast.Parse(in)
+		if err != nil {
+			b.Errorf("benchmark err: %s", err)
+		}
+	}
+}
+
+// benchSmallObjects is a large array of small objects, the worst case
+// for per-node allocation.
+var benchSmallObjects = func() []byte {
+	b := &bytes.Buffer{}
+	b.WriteString("[\n")
+	for i := 0; b.Len() < 8<<20; i++ {
+		fmt.Fprintf(b, "  {\"id\": %d, \"ok\": true, \"v\": null},\n", i)
+	}
+	b.WriteString("]\n")
+	return b.Bytes()
+}()
+
+func BenchmarkParseSmallObjects(b *testing.B) {
+	in := benchSmallObjects
+	b.SetBytes(int64(len(in)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := ast.Parse(in)
+		if err != nil {
+			b.Errorf("benchmark err: %s", err)
+		}
+	}
+}
+
+func BenchmarkParseSmallObjectsArena(b *testing.B) {
+	in := benchSmallObjects
+	b.SetBytes(int64(len(in)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := ast.Parse(in, ast.OptionArena)
+		if err != nil {
+			b.Errorf("benchmark err: %s", err)
+		}
+	}
+}
+
+func BenchmarkFmtLarge(b *testing.B) {
+	in := benchLarge
+	f, err := ast.Parse(in)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(in)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ast.FmtJsonr(f)
+	}
+}
+
 // func BenchmarkAst2FastStripReader(b *testing.B) {
 // 	in := benchChunk
 // 	br := bytes.NewReader(in)
@@ -166,6 +272,97 @@ func BenchmarkStripReader(b *testing.B) {
 // 	}
 // }
 
+type benchRecord struct {
+	ID      int               `json:"id"`
+	Name    string            `json:"name"`
+	Score   float64           `json:"score"`
+	Active  bool              `json:"active"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels"`
+	Parent  *benchRecord      `json:"parent"`
+	Ignored interface{}       `json:"-"`
+}
+
+var benchRecords = func() []byte {
+	b := &bytes.Buffer{}
+	b.WriteString("[\n")
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(b, `  {"id": %d, "name": "record %d", "score": %d.5, "active": true,
+    "tags": ["a", "b", "c"], "labels": {"env": "prod", "tier": "web"},
+    "parent": {"id": 1, "name": "root", "score": 0, "active": false, "tags": [], "labels": {}, "parent": null}},
+`, i, i, i)
+	}
+	b.WriteString("]\n")
+	return b.Bytes()
+}()
+
+func BenchmarkJSONUnmarshalStruct(b *testing.B) {
+	in, err := ast.Strip(benchRecords)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(in)))
+	for i := 0; i < b.N; i++ {
+		var out []benchRecord
+		if err := json.Unmarshal(in, &out); err != nil {
+			b.Errorf("benchmark err: %s", err)
+		}
+	}
+}
+
+func BenchmarkJSONRUnmarshalStruct(b *testing.B) {
+	in := benchRecords
+	b.SetBytes(int64(len(in)))
+	for i := 0; i < b.N; i++ {
+		var out []benchRecord
+		if err := Unmarshal(in, &out); err != nil {
+			b.Errorf("benchmark err: %s", err)
+		}
+	}
+}
+
+func BenchmarkJSONUnmarshalInterface(b *testing.B) {
+	in, err := ast.Strip(benchRecords)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(in)))
+	for i := 0; i < b.N; i++ {
+		var out interface{}
+		if err := json.Unmarshal(in, &out); err != nil {
+			b.Errorf("benchmark err: %s", err)
+		}
+	}
+}
+
+func BenchmarkJSONRUnmarshalInterface(b *testing.B) {
+	in := benchRecords
+	b.SetBytes(int64(len(in)))
+	for i := 0; i < b.N; i++ {
+		var out interface{}
+		if err := Unmarshal(in, &out); err != nil {
+			b.Errorf("benchmark err: %s", err)
+		}
+	}
+}
+
+func TestUnmarshalMatchesJSON(t *testing.T) {
+	in, err := ast.Strip(benchRecords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got []benchRecord
+	if err := json.Unmarshal(in, &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unmarshal(benchRecords, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatal("decoded records differ from encoding/json")
+	}
+}
+
 func TestStripRealistic(t *testing.T) {
 	in := vaguelyRealistic
 	out, err := ast.Strip(in)
@@ -185,3 +382,30 @@ func BenchmarkJSONRRealistic(b *testing.B) {
 		}
 	}
 }
+
+func TestHash(t *testing.T) {
+	a, err := Hash(vaguelyRealistic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Hash([]byte(`{"dict":{},"array":[],"z":null,"y":1,"x":"a string"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("expected equal hashes, got %x and %x", a, b)
+	}
+	c, err := Hash([]byte(`{"dict":{},"array":[],"z":null,"y":1,"x":"another string"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == c {
+		t.Errorf("expected different hashes, got %x", a)
+	}
+	// These would otherwise all hash as "\ufffd".
+	for _, in := range []string{`"\ud800"`, "\"\xff\""} {
+		if _, err := Hash([]byte(in)); err == nil {
+			t.Errorf("input %s: expected error", in)
+		}
+	}
+}
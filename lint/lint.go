@@ -0,0 +1,474 @@
+// Package lint checks JSONR files for problems that are valid syntax
+// but are likely mistakes or break a house style, such as duplicate
+// keys or inconsistently named fields.
+//
+// Rules are configured with a Config, typically read from a
+// .jsonr-lint.jsonr file, and can be disabled within a file by a
+// comment:
+//
+//	// jsonr-lint:disable=empty-container,todo
+//
+// A disable comment before the root value applies to the whole file.
+// Elsewhere it applies to the field or element the comment belongs to.
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/msolo/jsonr/ast"
+)
+
+// ConfigFile is the name of the file FindConfig looks for.
+const ConfigFile = ".jsonr-lint.jsonr"
+
+// Rule describes a check.
+type Rule struct {
+	Name string
+	Doc  string
+}
+
+// Rules lists every rule. SyntaxRule is not included; it cannot be
+// disabled.
+var Rules = []Rule{
+	{"duplicate-key", "an object has two fields with the same name"},
+	{"leading-zero", "a number has a leading zero, which JSON does not allow"},
+	{"key-style", "a key is not in the configured style, or the style of the first key in the file"},
+	{"comment-style", "a one-line comment does not use the style of the first in the file"},
+	{"empty-container", "an object or array is empty"},
+	{"todo", "a comment contains TODO or FIXME"},
+	{"license-header", "the comment before the root value lacks the configured license header"},
+	{"max-depth", "objects and arrays are nested more deeply than configured"},
+}
+
+// SyntaxRule is the rule reported for malformed input.
+const SyntaxRule = "syntax"
+
+// Key styles for Config.KeyStyle.
+const (
+	CamelCase = "camel" // fooBar
+	SnakeCase = "snake" // foo_bar
+	KebabCase = "kebab" // foo-bar
+)
+
+// Config selects and tunes the rules. The zero value enables every rule
+// except license-header and max-depth, which need a setting.
+type Config struct {
+	// Disable turns off the named rules.
+	Disable []string `json:"disable"`
+	// KeyStyle is the style every key must use. If empty, keys must use
+	// the style of the first key in the file that has one.
+	KeyStyle string `json:"key-style"`
+	// LicenseHeader is text that must appear in the comment before the
+	// root value.
+	LicenseHeader string `json:"license-header"`
+	// MaxDepth is the deepest that objects and arrays may be nested; the
+	// root is at depth 1. Zero means no limit.
+	MaxDepth int `json:"max-depth"`
+}
+
+// LoadConfig reads a Config from a JSONR file and checks it.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ast.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	c := &Config{}
+	if err := ast.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := c.validate(f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// FindConfig returns the path of the nearest ConfigFile in dir or its
+// parents, or "" if there is none.
+func FindConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ConfigFile)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ConfigFinder loads the Config for each file from the nearest
+// ConfigFile to it, reading each ConfigFile once. It is safe for
+// concurrent use.
+type ConfigFinder struct {
+	mu     sync.Mutex
+	loaded map[string]*loadedConfig // by ConfigFile path
+}
+
+type loadedConfig struct {
+	config *Config
+	err    error
+}
+
+// Config returns the Config for files in dir, or the zero Config if
+// there is no ConfigFile in dir or its parents.
+func (cf *ConfigFinder) Config(dir string) (*Config, error) {
+	found := FindConfig(dir)
+	if found == "" {
+		return &Config{}, nil
+	}
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	l, ok := cf.loaded[found]
+	if !ok {
+		if cf.loaded == nil {
+			cf.loaded = make(map[string]*loadedConfig)
+		}
+		l = &loadedConfig{}
+		l.config, l.err = LoadConfig(found)
+		cf.loaded[found] = l
+	}
+	return l.config, l.err
+}
+
+// validate checks the settings of c, which was decoded from f.
+func (c *Config) validate(f *ast.File) error {
+	if o, ok := f.Root.(*ast.Object); ok {
+		for _, fl := range o.Fields {
+			if !isConfigKey(fl.Key.Name) {
+				return fmt.Errorf("unknown key %q", fl.Key.Name)
+			}
+		}
+	}
+	for _, name := range c.Disable {
+		if !isRule(name) {
+			return fmt.Errorf("unknown rule %q", name)
+		}
+	}
+	switch c.KeyStyle {
+	case "", CamelCase, SnakeCase, KebabCase:
+	default:
+		return fmt.Errorf("invalid key-style %q: must be %q, %q or %q", c.KeyStyle, CamelCase, SnakeCase, KebabCase)
+	}
+	if c.MaxDepth < 0 {
+		return fmt.Errorf("invalid max-depth %d", c.MaxDepth)
+	}
+	return nil
+}
+
+// isConfigKey reports whether name sets a field of Config, matching it
+// as decoding does.
+func isConfigKey(name string) bool {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if strings.EqualFold(t.Field(i).Tag.Get("json"), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isRule(name string) bool {
+	for _, r := range Rules {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Diagnostic is a problem found by a rule.
+type Diagnostic struct {
+	Pos  ast.Position
+	Rule string
+	Msg  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s (%s)", d.Pos, d.Msg, d.Rule)
+}
+
+// File parses src as the named file and checks it. Syntax errors are
+// reported as diagnostics of the SyntaxRule, and the rest of the file
+// is still checked as far as it can be parsed.
+func (c *Config) File(filename string, src []byte) []Diagnostic {
+	fset := ast.NewFileSet()
+	f, err := ast.ParseFile(fset, filename, src, ast.OptionRecover)
+	var diags []Diagnostic
+	if el, ok := err.(ast.ErrorList); ok {
+		for _, e := range el {
+			diags = append(diags, Diagnostic{Pos: e.Pos, Rule: SyntaxRule, Msg: e.Msg})
+		}
+	} else if err != nil {
+		diags = append(diags, Diagnostic{Pos: ast.Position{Filename: filename}, Rule: SyntaxRule, Msg: err.Error()})
+	}
+	if f != nil {
+		diags = append(diags, c.Check(fset, f)...)
+	}
+	sort.SliceStable(diags, func(i, j int) bool { return diags[i].Pos.Offset < diags[j].Pos.Offset })
+	return diags
+}
+
+// Check runs the enabled rules over f, whose positions belong to fset,
+// in source order.
+func (c *Config) Check(fset *ast.FileSet, f *ast.File) []Diagnostic {
+	ck := &checker{config: c, disabled: make(map[string]bool)}
+	for _, name := range c.Disable {
+		ck.disabled[name] = true
+	}
+	ck.file(f)
+	var diags []Diagnostic
+	for _, f := range ck.findings {
+		if !ck.suppressed(f) {
+			diags = append(diags, Diagnostic{Pos: fset.Position(f.pos), Rule: f.rule, Msg: f.msg})
+		}
+	}
+	sort.SliceStable(diags, func(i, j int) bool { return diags[i].Pos.Offset < diags[j].Pos.Offset })
+	return diags
+}
+
+// suppression is a range of the file in which rules are disabled by a
+// comment.
+type suppression struct {
+	from, to ast.Pos
+	rules    []string
+}
+
+type checker struct {
+	config   *Config
+	disabled map[string]bool
+
+	keyStyle     string // of the first styled key, if not configured
+	commentStyle string // "//" or "/*", of the first one-line comment
+	suppressions []suppression
+	findings     []finding
+}
+
+type finding struct {
+	pos  ast.Pos
+	rule string
+	msg  string
+}
+
+func (ck *checker) report(pos ast.Pos, rule, format string, args ...interface{}) {
+	if !ck.disabled[rule] {
+		ck.findings = append(ck.findings, finding{pos, rule, fmt.Sprintf(format, args...)})
+	}
+}
+
+// suppress records the disable comments among groups, which apply to
+// node and the groups themselves.
+func (ck *checker) suppress(node ast.Node, groups ...*ast.CommentGroup) {
+	from, to := node.Pos(), node.End()
+	var rules []string
+	for _, g := range groups {
+		if g == nil {
+			continue
+		}
+		if g.Pos() < from {
+			from = g.Pos()
+		}
+		if g.End() > to {
+			to = g.End()
+		}
+		for _, c := range g.List {
+			rules = append(rules, disabledRules(c.Text)...)
+		}
+	}
+	if len(rules) > 0 {
+		ck.suppressions = append(ck.suppressions, suppression{from, to, rules})
+	}
+}
+
+func (ck *checker) suppressed(f finding) bool {
+	for _, s := range ck.suppressions {
+		if s.from <= f.pos && f.pos < s.to {
+			for _, r := range s.rules {
+				if r == f.rule {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+var disableRe = regexp.MustCompile(`jsonr-lint:disable=([\w,-]+)`)
+
+// disabledRules returns the rules named by a disable comment.
+func disabledRules(text []byte) []string {
+	m := disableRe.FindSubmatch(text)
+	if m == nil {
+		return nil
+	}
+	return strings.Split(string(m[1]), ",")
+}
+
+func (ck *checker) file(f *ast.File) {
+	if f.Root != nil {
+		ck.suppress(f, f.Doc)
+	}
+	ck.licenseHeader(f)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if c, ok := n.(*ast.Comment); ok {
+			ck.comment(c)
+		}
+		return true
+	})
+	if f.Root != nil {
+		ck.value(f.Root, 1)
+	}
+}
+
+func (ck *checker) licenseHeader(f *ast.File) {
+	header := ck.config.LicenseHeader
+	if header == "" {
+		return
+	}
+	if f.Doc != nil {
+		for _, c := range f.Doc.List {
+			if bytes.Contains(c.Text, []byte(header)) {
+				return
+			}
+		}
+	}
+	pos := f.Pos()
+	if !pos.IsValid() {
+		return
+	}
+	ck.report(pos, "license-header", "missing license header %q", header)
+}
+
+func (ck *checker) comment(c *ast.Comment) {
+	if disabledRules(c.Text) != nil {
+		return
+	}
+	if todoRe.Match(c.Text) {
+		ck.report(c.Pos(), "todo", "comment contains %s", todoRe.Find(c.Text))
+	}
+	// Multi-line block comments have no one-line equivalent, so only
+	// one-line comments need to agree.
+	if bytes.IndexByte(c.Text, '\n') >= 0 {
+		return
+	}
+	style := string(c.Text[:2])
+	if ck.commentStyle == "" {
+		ck.commentStyle = style
+	} else if style != ck.commentStyle {
+		ck.report(c.Pos(), "comment-style", "%s comment, but the file uses %s comments", style, ck.commentStyle)
+	}
+}
+
+var todoRe = regexp.MustCompile(`\b(TODO|FIXME)\b`)
+
+func (ck *checker) value(v ast.Value, depth int) {
+	if max := ck.config.MaxDepth; max > 0 && depth == max+1 {
+		// Report only the outermost container that is too deep.
+		switch v.(type) {
+		case *ast.Object, *ast.Array:
+			ck.report(v.Pos(), "max-depth", "nested %d deep, more than the maximum of %d", depth, max)
+		}
+	}
+	switch v := v.(type) {
+	case *ast.Literal:
+		if v.Type == ast.LiteralNumber && hasLeadingZero(v.Value) {
+			ck.report(v.Pos(), "leading-zero", "number %s has a leading zero", v.Value)
+		}
+	case *ast.Object:
+		ck.suppress(v, v.Doc, v.Comment)
+		if len(v.Fields) == 0 {
+			ck.report(v.Pos(), "empty-container", "empty object")
+		}
+		seen := make(map[string]bool, len(v.Fields))
+		for _, f := range v.Fields {
+			ck.suppress(f, f.Doc, f.Comment)
+			if seen[f.Key.Name] {
+				ck.report(f.Pos(), "duplicate-key", "duplicate key %s", f.Key.Raw)
+			}
+			seen[f.Key.Name] = true
+			ck.key(f.Key)
+			ck.value(f.Value, depth+1)
+		}
+	case *ast.Array:
+		if len(v.Elements) == 0 {
+			ck.report(v.Pos(), "empty-container", "empty array")
+		}
+		for _, e := range v.Elements {
+			ck.suppress(e, e.Doc, e.Comment)
+			ck.value(e.Value, depth+1)
+		}
+	}
+}
+
+// hasLeadingZero reports whether a number like 007 or -01.5 has a zero
+// before other digits in its integer part.
+func hasLeadingZero(num []byte) bool {
+	num = bytes.TrimPrefix(num, []byte("-"))
+	return len(num) > 1 && num[0] == '0' && '0' <= num[1] && num[1] <= '9'
+}
+
+func (ck *checker) key(k *ast.Key) {
+	style := keyStyle(k.Name)
+	if style == "" {
+		return
+	}
+	want := ck.config.KeyStyle
+	if want == "" {
+		if ck.keyStyle == "" {
+			ck.keyStyle = style
+			return
+		}
+		want = ck.keyStyle
+	}
+	if style != want {
+		ck.report(k.Pos(), "key-style", "key %s is %s case, expected %s case", k.Raw, style, want)
+	}
+}
+
+// keyStyle returns the style of a key name, or "" if it has none, like
+// a single lowercase word, or mixes styles.
+func keyStyle(name string) string {
+	var upper, underscore, dash bool
+	for _, r := range name {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case r == '_':
+			underscore = true
+		case r == '-':
+			dash = true
+		case unicode.IsLower(r) || unicode.IsDigit(r):
+		default:
+			// Keys like paths or sentences are not identifiers.
+			return ""
+		}
+	}
+	switch {
+	case upper && !underscore && !dash && unicode.IsLower([]rune(name)[0]):
+		return CamelCase
+	case underscore && !upper && !dash:
+		return SnakeCase
+	case dash && !upper && !underscore:
+		return KebabCase
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package lint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func summarize(diags []Diagnostic) string {
+	var s []string
+	for _, d := range diags {
+		s = append(s, d.Pos.String()+" "+d.Rule)
+	}
+	return strings.Join(s, "; ")
+}
+
+func TestRules(t *testing.T) {
+	for _, tc := range []struct {
+		config   Config
+		in       string
+		expected string
+	}{
+		{Config{}, `{"a": 1, "b": 2, "a": 3}`, "1:18 duplicate-key"},
+		{Config{}, `[0, 0.5, -0, 01, -007.5]`, "1:14 leading-zero; 1:18 leading-zero"},
+		{Config{}, `{"fooBar": 1, "plain": 2, "foo_bar": 3, "foo-bar": 4, "a/b": 5}`, "1:27 key-style; 1:41 key-style"},
+		{Config{KeyStyle: SnakeCase}, `{"fooBar": 1, "foo_bar": 2}`, "1:2 key-style"},
+		{Config{}, "// a\n/* b */\n/*\n c\n*/\n[1]", "2:1 comment-style"},
+		{Config{}, `{"a": [], "b": {}}`, "1:7 empty-container; 1:16 empty-container"},
+		{Config{}, "[1, // TODO: more\n 2] // FIXME\n", "1:5 todo; 2:5 todo"},
+		{Config{}, "[1] // TODOS are fine", ""},
+		{Config{LicenseHeader: "Copyright"}, "// Copyright 2020\n[1]", ""},
+		{Config{LicenseHeader: "Copyright"}, "// Hello\n[1]", "1:1 license-header"},
+		{Config{MaxDepth: 2}, `[[1], [[2, [3]]], {"a": {"b": 1}}]`, "1:8 max-depth; 1:25 max-depth"},
+		{Config{Disable: []string{"empty-container"}}, `[[], {}]`, ""},
+		{Config{}, `{"a": tru, "b": {}}`, "1:7 syntax; 1:17 empty-container"},
+	} {
+		c := tc.config
+		if got := summarize(c.File("", []byte(tc.in))); got != tc.expected {
+			t.Errorf("input %q: expected %q, got %q", tc.in, tc.expected, got)
+		}
+	}
+}
+
+func TestSyntaxMessage(t *testing.T) {
+	// The bad token is quoted on its own, not the rest of the line.
+	diags := (&Config{}).File("x.jsonr", []byte("{\"a\": x}\n"))
+	if len(diags) != 1 || diags[0].String() != `x.jsonr:1:7: invalid element: "x" (syntax)` {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+}
+
+func TestDisableComments(t *testing.T) {
+	in := `// jsonr-lint:disable=todo
+{
+  // jsonr-lint:disable=empty-container,duplicate-key
+  "a": {"b": [], "b": 1},
+  "c": [], // jsonr-lint:disable=empty-container
+  "d": [ // TODO
+    [],
+  ],
+}
+`
+	c := &Config{}
+	if got, expected := summarize(c.File("", []byte(in))), "7:5 empty-container"; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0775); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, ConfigFile)
+	config := `{
+  // Empty defaults are fine here.
+  "disable": ["empty-container"],
+  "key-style": "kebab",
+  "max-depth": 4,
+}`
+	if err := ioutil.WriteFile(path, []byte(config), 0664); err != nil {
+		t.Fatal(err)
+	}
+	if found := FindConfig(sub); found != path {
+		t.Errorf("expected to find %s, got %q", path, found)
+	}
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Disable) != 1 || c.KeyStyle != KebabCase || c.MaxDepth != 4 {
+		t.Errorf("unexpected config %+v", c)
+	}
+
+	for _, bad := range []string{`{"disable": ["nope"]}`, `{"key-style": "pascal"}`, `{"max-depth": -1}`, `{`, `{"max_depth": 2}`, `{"disabled": []}`} {
+		if err := ioutil.WriteFile(path, []byte(bad), 0664); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("config %s: expected error", bad)
+		}
+	}
+}
+
+func TestConfigFinder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	nested := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(nested, 0775); err != nil {
+		t.Fatal(err)
+	}
+	for path, config := range map[string]string{
+		filepath.Join(dir, ConfigFile):        `{"max-depth": 4}`,
+		filepath.Join(dir, "a", ConfigFile):   `{"max-depth": 2}`,
+		filepath.Join(nested, "ignored.json"): `{"max-depth": 1}`,
+	} {
+		if err := ioutil.WriteFile(path, []byte(config), 0664); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cf := &ConfigFinder{}
+	for d, depth := range map[string]int{dir: 4, filepath.Join(dir, "a"): 2, nested: 2} {
+		c, err := cf.Config(d)
+		if err != nil || c.MaxDepth != depth {
+			t.Errorf("%s: expected max-depth %d, got %+v, %v", d, depth, c, err)
+		}
+	}
+	a, _ := cf.Config(filepath.Join(dir, "a"))
+	b, _ := cf.Config(nested)
+	if a != b {
+		t.Error("expected a ConfigFile to be loaded once")
+	}
+}